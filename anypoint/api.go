@@ -0,0 +1,299 @@
+package anypoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/jchawla2804/golang-slack-event-listener/logging"
+	"github.com/jchawla2804/golang-slack-event-listener/model"
+)
+
+// GetToken retrieves the token details based on the type of authentication.
+// It takes the username, password, and typeOfAuth as input parameters.
+// It returns the token details and an error if any.
+func (c *Client) GetToken(ctx context.Context, username, password, typeOfAuth string) (model.Authorization, error) {
+	slog.DebugContext(ctx, "Retrieving Anypoint token", "auth_type", typeOfAuth)
+	tokenDetails := model.Authorization{}
+	var path string
+	var body map[string]string
+
+	switch typeOfAuth {
+	case "basic-auth":
+		body = map[string]string{
+			"username": username,
+			"password": password,
+		}
+
+		path = "accounts/login"
+
+	case "oauth":
+		body = map[string]string{
+			"client_id":     username,
+			"client_secret": password,
+			"grant_type":    "client_credentials",
+		}
+		path = "accounts/api/v2/oauth2/token"
+
+	default:
+		return tokenDetails, errors.New("invalid Auth Type")
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, path, body)
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not build Anypoint request", "err", err)
+		return tokenDetails, err
+	}
+
+	err = c.do(req, &tokenDetails)
+	return tokenDetails, err
+
+}
+
+// GetPlatformInformation retrieves the platform information of the logged in user.
+// It returns the platform details and an error if any.
+func (c *Client) GetPlatformInformation(ctx context.Context) (model.AnypointPlatform, error) {
+	platformDetails := model.AnypointPlatform{}
+
+	req, err := c.newAuthorizedRequest(ctx, http.MethodGet, "accounts/api/me", nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not build Anypoint request", "err", err)
+		return platformDetails, err
+	}
+
+	err = c.do(req, &platformDetails)
+	return platformDetails, err
+}
+
+// GetAppDetails retrieves the status of all applications deployed in a MuleSoft environment.
+// It takes the envId and orgId as input parameters.
+// It returns the application details and an error if any.
+func (c *Client) GetAppDetails(ctx context.Context, envId, orgId string) (string, error) {
+	appDetails := []model.ApplicationDetails{}
+
+	req, err := c.newAuthorizedRequest(ctx, http.MethodGet, "cloudhub/api/v2/applications", nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not build Anypoint request", "err", err)
+		return "", err
+	}
+
+	req.Header.Add("X-ANYPNT-ORG-ID", orgId)
+	req.Header.Add("X-ANYPNT-ENV-ID", envId)
+
+	err = c.do(req, &appDetails)
+	if err != nil {
+		return "", err
+	}
+
+	var concatenatedString []string
+
+	for _, value := range appDetails {
+		concatenatedString = append(concatenatedString, "Name : "+value.Domain+"\n"+"Status : "+value.Status+"\n"+"Workers Cpu : "+value.Workers.Type.CPU+"\n")
+	}
+
+	return ("```" + strings.Join(concatenatedString, "\n\n") + "```"), nil
+
+}
+
+// GetApplication retrieves the details of a single application deployed in a MuleSoft environment.
+// It takes the envId, orgId, and appName as input parameters.
+// It returns the application details and an error if any.
+func (c *Client) GetApplication(ctx context.Context, envId, orgId, appName string) (model.ApplicationDetails, error) {
+	appDetails := model.ApplicationDetails{}
+
+	req, err := c.newAuthorizedRequest(ctx, http.MethodGet, "cloudhub/api/applications/"+url.PathEscape(appName), nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not build Anypoint request", "err", err)
+		return appDetails, err
+	}
+	req.Header.Add("X-ANYPNT-ORG-ID", orgId)
+	req.Header.Add("X-ANYPNT-ENV-ID", envId)
+
+	err = c.do(req, &appDetails)
+	return appDetails, err
+}
+
+// ChangeAppStatus changes the status of an application (stop, start, restart).
+// It takes the status, envId, orgId, and appName as input parameters.
+// It returns a boolean indicating the success of the status change and an error if any.
+func (c *Client) ChangeAppStatus(ctx context.Context, status string, envId, orgId string, appName string) (bool, error) {
+	body := map[string]string{
+		"status": status,
+	}
+
+	req, err := c.newAuthorizedRequest(ctx, http.MethodPost, "cloudhub/api/applications/"+url.PathEscape(appName)+"/status", body)
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not build Anypoint request", "err", err)
+		return false, err
+	}
+	req.Header.Add("X-ANYPNT-ORG-ID", orgId)
+	req.Header.Add("X-ANYPNT-ENV-ID", envId)
+
+	err = c.do(req, nil)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+
+}
+
+// ScaleApp changes the number of workers of an application.
+// It takes the envId, orgId, appName, and workers as input parameters.
+// It returns an error if any.
+func (c *Client) ScaleApp(ctx context.Context, envId, orgId, appName string, workers int) error {
+	body := map[string]interface{}{
+		"workers": map[string]int{"amount": workers},
+	}
+
+	req, err := c.newAuthorizedRequest(ctx, http.MethodPut, "cloudhub/api/applications/"+url.PathEscape(appName), body)
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not build Anypoint request", "err", err)
+		return err
+	}
+	req.Header.Add("X-ANYPNT-ORG-ID", orgId)
+	req.Header.Add("X-ANYPNT-ENV-ID", envId)
+
+	return c.do(req, nil)
+}
+
+// SetAppProperty sets one application property and keeps all the others.
+// CloudHub replaces the whole property set on update, so the current properties are read first.
+// It takes the envId, orgId, appName, name, and value as input parameters.
+// It returns an error if any.
+func (c *Client) SetAppProperty(ctx context.Context, envId, orgId, appName, name, value string) error {
+	app, err := c.GetApplication(ctx, envId, orgId, appName)
+	if err != nil {
+		return err
+	}
+
+	properties := map[string]string{}
+	for k, v := range app.Properties {
+		properties[k] = v
+	}
+	properties[name] = value
+	if app.PropertiesOptions[name].Secure {
+		logging.AddSecret(value)
+	}
+
+	req, err := c.newAuthorizedRequest(ctx, http.MethodPut, "cloudhub/api/applications/"+url.PathEscape(appName), map[string]interface{}{"properties": properties})
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not build Anypoint request", "err", err)
+		return err
+	}
+	req.Header.Add("X-ANYPNT-ORG-ID", orgId)
+	req.Header.Add("X-ANYPNT-ENV-ID", envId)
+
+	return c.do(req, nil)
+}
+
+// GetAssetInfo retrieves the information of all assets of an organization.
+// It takes the orgId as an input parameter.
+// It returns the asset details and an error if any.
+func (c *Client) GetAssetInfo(ctx context.Context, orgId string) (string, error) {
+	assetDetails := []model.AssetInformation{}
+
+	req, err := c.newAuthorizedRequest(ctx, http.MethodGet, "exchange/api/v1/assets", nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not build Anypoint request", "err", err)
+		return "", err
+	}
+	q := req.URL.Query()
+	q.Add("organizationId", orgId)
+	req.URL.RawQuery = q.Encode()
+
+	err = c.do(req, &assetDetails)
+	if err != nil {
+		return "", err
+	}
+
+	var concatenatedString []string
+
+	for _, value := range assetDetails {
+		concatenatedString = append(concatenatedString, fmt.Sprintf("Asset Name :- %s\n Group Id :- %s\n Asset Id :- %s\n Version :- %s\n Asset Link :- %s\n Description :- %s", value.Name, value.GroupID, value.AssetID, value.Version, value.AssetLink, value.Description))
+	}
+
+	return ("```" + strings.Join(concatenatedString, "\n\n") + "```"), nil
+
+}
+
+// ListEnvironments lists all the environments in a MuleSoft business group.
+// It takes the orgId as an input parameter.
+// It returns the list of environments and an error if any.
+func (c *Client) ListEnvironments(ctx context.Context, orgId string) (model.ListOfEnv, error) {
+	envDetails := model.ListOfEnv{}
+
+	req, err := c.newAuthorizedRequest(ctx, http.MethodGet, "accounts/api/organizations/"+url.PathEscape(orgId)+"/environments", nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not build Anypoint request", "err", err)
+		return model.ListOfEnv{}, err
+	}
+
+	err = c.do(req, &envDetails)
+	return envDetails, err
+}
+
+// DownloadAsset downloads an asset from Anypoint Exchange.
+// It takes the orgid and assetName as input parameters.
+// It returns the path of a temporary file holding the asset, which the caller removes, and an error if any.
+func (c *Client) DownloadAsset(ctx context.Context, orgid, assetName string) (string, error) {
+	specificAssetDetails := model.AssetDownload{}
+
+	req, err := c.newAuthorizedRequest(ctx, http.MethodGet, "exchange/api/v1/assets/"+url.PathEscape(orgid)+"/"+url.PathEscape(assetName), nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not build Anypoint request", "err", err)
+		return "", err
+	}
+
+	err = c.do(req, &specificAssetDetails)
+	if err != nil {
+		return "", err
+	}
+
+	if len(specificAssetDetails.Files) == 0 {
+		return "", fmt.Errorf("asset %s has no downloadable files", assetName)
+	}
+	assetLink := specificAssetDetails.Files[0].ExternalLink
+	fileExtension := specificAssetDetails.Files[0].Packaging
+
+	fileReq, err := http.NewRequestWithContext(ctx, http.MethodGet, assetLink, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not build asset download request", "asset", assetName, "err", err)
+		return "", err
+	}
+	fileReq, cancel := c.withTimeout(fileReq)
+	defer cancel()
+	fileresp, err := c.httpClient.Do(fileReq)
+	if err != nil {
+		slog.ErrorContext(ctx, "Asset download failed", "asset", assetName, "err", err)
+		return "", err
+	}
+	defer fileresp.Body.Close()
+	if fileresp.StatusCode != http.StatusOK {
+		return "", newAPIError(fileresp)
+	}
+
+	// Concurrent downloads of the same asset must not share a file, and the asset name comes from the user.
+	out, err := os.CreateTemp("", "asset-*."+fileExtension)
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not create asset file", "asset", assetName, "err", err)
+		return "", err
+	}
+
+	defer out.Close()
+	_, err = io.Copy(out, fileresp.Body)
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not write asset file", "asset", assetName, "err", err)
+		os.Remove(out.Name())
+		return "", err
+	}
+
+	return out.Name(), nil
+
+}
@@ -0,0 +1,213 @@
+package anypoint
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jchawla2804/golang-slack-event-listener/metrics"
+)
+
+// ControlPlane is an Anypoint Platform region with its own accounts and APIs.
+type ControlPlane string
+
+const (
+	US  ControlPlane = "us"
+	EU  ControlPlane = "eu"
+	Gov ControlPlane = "gov"
+)
+
+var controlPlaneURLs = map[ControlPlane]string{
+	US:  "https://anypoint.mulesoft.com/",
+	EU:  "https://eu1.anypoint.mulesoft.com/",
+	Gov: "https://gov.anypoint.mulesoft.com/",
+}
+
+// ControlPlanes lists the selectable control planes in the order they are offered to users.
+var ControlPlanes = []ControlPlane{US, EU, Gov}
+
+// ParseControlPlane returns the control plane with the given name. An empty name selects US.
+func ParseControlPlane(name string) (ControlPlane, error) {
+	if name == "" {
+		return US, nil
+	}
+	plane := ControlPlane(strings.ToLower(name))
+	if _, found := controlPlaneURLs[plane]; !found {
+		return "", fmt.Errorf("unknown control plane %q", name)
+	}
+	return plane, nil
+}
+
+// BaseURL returns the root URL of the control plane APIs.
+func (p ControlPlane) BaseURL() string {
+	if url, found := controlPlaneURLs[p]; found {
+		return url
+	}
+	return controlPlaneURLs[US]
+}
+
+// AuthProvider supplies the bearer token sent with every authenticated API call.
+type AuthProvider interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken is an AuthProvider for a token that is used as is.
+type StaticToken string
+
+func (t StaticToken) Token(ctx context.Context) (string, error) {
+	return string(t), nil
+}
+
+// Client calls the Anypoint Platform APIs of one control plane.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	timeout    time.Duration
+	userAgent  string
+	auth       AuthProvider
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// OptionControlPlane points the client at the APIs of a control plane.
+func OptionControlPlane(plane ControlPlane) Option {
+	return func(c *Client) {
+		c.baseURL = plane.BaseURL()
+	}
+}
+
+// OptionBaseURL points the client at any base URL, e.g. an httptest.Server.
+func OptionBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/") + "/"
+	}
+}
+
+// OptionHTTPClient replaces the HTTP client used for all calls.
+// Wrap its transport with NewRetryTransport to keep retrying failed GET calls.
+func OptionHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// OptionTimeout sets the timeout of every call, including reading the response body.
+// It applies to the HTTP client of OptionHTTPClient as well; zero means no timeout.
+func OptionTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// OptionUserAgent sets the User-Agent header of every call.
+func OptionUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// OptionAuth sets the provider of the bearer token.
+func OptionAuth(auth AuthProvider) Option {
+	return func(c *Client) {
+		c.auth = auth
+	}
+}
+
+// New creates a client for the US control plane unless an option says otherwise.
+func New(options ...Option) *Client {
+	c := &Client{
+		baseURL:    US.BaseURL(),
+		httpClient: &http.Client{Transport: NewRetryTransport(http.DefaultTransport)},
+		timeout:    60 * time.Second,
+		userAgent:  "golang-slack-event-listener",
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// newRequest builds a request against the control plane, encoding body as JSON when it is not nil.
+func (c *Client) newRequest(ctx context.Context, method, path string, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		dataInBytes, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewBuffer(dataInBytes)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+strings.TrimPrefix(path, "/"), reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	return req, nil
+}
+
+// newAuthorizedRequest is newRequest with the bearer token of the auth provider.
+func (c *Client) newAuthorizedRequest(ctx context.Context, method, path string, body interface{}) (*http.Request, error) {
+	req, err := c.newRequest(ctx, method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.auth == nil {
+		return nil, fmt.Errorf("no auth provider configured for %s", path)
+	}
+
+	token, err := c.auth.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+	return req, nil
+}
+
+// withTimeout bounds req by the client timeout. The caller calls cancel once the response body is read.
+func (c *Client) withTimeout(req *http.Request) (*http.Request, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return req, func() {}
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), c.timeout)
+	return req.WithContext(ctx), cancel
+}
+
+// do sends the request and decodes a successful JSON response into out when it is not nil.
+// Any status other than 2xx is returned as an *APIError.
+func (c *Client) do(req *http.Request, out interface{}) error {
+	req, cancel := c.withTimeout(req)
+	defer cancel()
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		metrics.AnypointCall(req.Method, req.URL.Path, 0, time.Since(start))
+		slog.ErrorContext(req.Context(), "Anypoint request failed", "method", req.Method, "path", req.URL.Path, "err", err)
+		return err
+	}
+	defer resp.Body.Close()
+	metrics.AnypointCall(req.Method, req.URL.Path, resp.StatusCode, time.Since(start))
+	recordRequestID(req.Context(), resp.Header.Get("X-Request-Id"))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := newAPIError(resp)
+		slog.WarnContext(req.Context(), "Anypoint returned an error", "method", req.Method, "path", req.URL.Path, "status", apiErr.StatusCode, "request_id", apiErr.RequestID, "err", apiErr)
+		return apiErr
+	}
+
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
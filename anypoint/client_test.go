@@ -0,0 +1,60 @@
+package anypoint
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPathSegmentsAreEscaped(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	client := New(OptionBaseURL(server.URL), OptionAuth(StaticToken("token")))
+
+	client.GetApplication(context.Background(), "env", "org", "../../accounts/api/me")
+	client.ListEnvironments(context.Background(), "org?x=1")
+	client.ChangeAppStatus(context.Background(), "stop", "env", "org", "app/other")
+
+	want := []string{
+		"/cloudhub/api/applications/..%2F..%2Faccounts%2Fapi%2Fme",
+		"/accounts/api/organizations/org%3Fx=1/environments",
+		"/cloudhub/api/applications/app%2Fother/status",
+	}
+	if len(paths) != len(want) {
+		t.Fatalf("server got paths %v, want %v", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Errorf("path = %q, want %q", paths[i], want[i])
+		}
+	}
+}
+
+func TestOptionTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	shared := &http.Client{}
+	client := New(OptionTimeout(20*time.Millisecond), OptionBaseURL(server.URL), OptionHTTPClient(shared), OptionAuth(StaticToken("token")))
+
+	_, err := client.GetApplication(context.Background(), "env", "org", "app")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetApplication = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if shared.Timeout != 0 {
+		t.Errorf("OptionTimeout changed the shared HTTP client timeout to %v", shared.Timeout)
+	}
+}
@@ -0,0 +1,81 @@
+package anypoint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrRateLimited  = errors.New("rate limited")
+)
+
+// APIError is returned when the Anypoint Platform answers a call with an unexpected status.
+// It matches ErrUnauthorized, ErrForbidden, ErrNotFound and ErrRateLimited with errors.Is.
+type APIError struct {
+	StatusCode int
+	Endpoint   string
+	Message    string
+	RequestID  string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("anypoint %s returned %d", e.Endpoint, e.StatusCode)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.RequestID != "" {
+		msg += " (request id " + e.RequestID + ")"
+	}
+	return msg
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// newAPIError builds an APIError from a failed response and consumes its body.
+func newAPIError(resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Endpoint:   resp.Request.Method + " " + resp.Request.URL.Path,
+		Message:    errorMessage(body),
+		RequestID:  resp.Header.Get("X-Request-Id"),
+	}
+}
+
+// errorMessage pulls the human readable message out of an Anypoint error body.
+// The APIs do not agree on a format, so the common field names are tried in turn.
+func errorMessage(body []byte) string {
+	fields := map[string]interface{}{}
+	if json.Unmarshal(body, &fields) == nil {
+		for _, name := range []string{"message", "error_description", "error", "name"} {
+			if value, ok := fields[name].(string); ok && value != "" {
+				return value
+			}
+		}
+	}
+
+	msg := strings.TrimSpace(string(body))
+	if len(msg) > 200 {
+		msg = msg[:200] + "..."
+	}
+	return msg
+}
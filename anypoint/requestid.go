@@ -0,0 +1,38 @@
+package anypoint
+
+import (
+	"context"
+	"sync"
+)
+
+type requestIDsKey struct{}
+
+// RequestIDs collects the x-request-id of every Anypoint response to calls made with its context,
+// so a Slack command can be tied to the API calls it caused.
+type RequestIDs struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+// WithRequestIDs returns a context whose API calls record their request ids in the returned RequestIDs.
+func WithRequestIDs(ctx context.Context) (context.Context, *RequestIDs) {
+	ids := &RequestIDs{}
+	return context.WithValue(ctx, requestIDsKey{}, ids), ids
+}
+
+// List returns the recorded request ids in the order the responses arrived.
+func (r *RequestIDs) List() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.ids...)
+}
+
+func recordRequestID(ctx context.Context, id string) {
+	ids, ok := ctx.Value(requestIDsKey{}).(*RequestIDs)
+	if !ok || id == "" {
+		return
+	}
+	ids.mu.Lock()
+	ids.ids = append(ids.ids, id)
+	ids.mu.Unlock()
+}
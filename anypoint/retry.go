@@ -0,0 +1,118 @@
+package anypoint
+
+import (
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryTransport retries idempotent requests that fail with a network error or a
+// 429, 502, 503 or 504 status. Requests that change state, such as a status change,
+// are sent exactly once.
+type RetryTransport struct {
+	Base        http.RoundTripper
+	MaxAttempts int
+	// BaseDelay is the backoff before the second attempt. It doubles with every attempt up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// MaxRetryAfter caps how long a Retry-After header may make a call wait. Longer waits are not retried.
+	MaxRetryAfter time.Duration
+}
+
+// NewRetryTransport wraps base with the default retry policy.
+func NewRetryTransport(base http.RoundTripper) *RetryTransport {
+	return &RetryTransport{
+		Base:          base,
+		MaxAttempts:   4,
+		BaseDelay:     500 * time.Millisecond,
+		MaxDelay:      8 * time.Second,
+		MaxRetryAfter: 30 * time.Second,
+	}
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return t.Base.RoundTrip(req)
+	}
+
+	var resp *http.Response
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		resp, err = t.Base.RoundTrip(req)
+		if attempt >= t.MaxAttempts || !retryable(resp, err) {
+			break
+		}
+
+		delay := t.backoff(attempt)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				if retryAfter > t.MaxRetryAfter {
+					break
+				}
+				delay = retryAfter
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			slog.WarnContext(req.Context(), "Retrying Anypoint call", "method", req.Method, "path", req.URL.Path, "attempt", attempt, "max_attempts", t.MaxAttempts, "status", resp.StatusCode, "delay", delay)
+		} else {
+			slog.WarnContext(req.Context(), "Retrying Anypoint call", "method", req.Method, "path", req.URL.Path, "attempt", attempt, "max_attempts", t.MaxAttempts, "err", err, "delay", delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+
+	if err != nil {
+		slog.ErrorContext(req.Context(), "Anypoint call failed", "method", req.Method, "path", req.URL.Path, "attempts", attempt, "err", err)
+	} else {
+		slog.DebugContext(req.Context(), "Anypoint call finished", "method", req.Method, "path", req.URL.Path, "status", resp.StatusCode, "attempts", attempt)
+	}
+	return resp, err
+}
+
+// backoff returns a random delay between zero and the exponential backoff of the attempt.
+func (t *RetryTransport) backoff(attempt int) time.Duration {
+	limit := t.BaseDelay << (attempt - 1)
+	if limit > t.MaxDelay || limit <= 0 {
+		limit = t.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(limit) + 1))
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
@@ -0,0 +1,131 @@
+package anypoint
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failingServer answers the first failures calls with status and retryAfter, and 200 afterwards.
+// It returns the client to call it with and the number of calls it received.
+func failingServer(t *testing.T, failures int, status int, retryAfter string) (*Client, *atomic.Int32) {
+	t.Helper()
+	calls := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if int(calls.Add(1)) <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+
+	transport := NewRetryTransport(http.DefaultTransport)
+	transport.BaseDelay = time.Millisecond
+	transport.MaxDelay = time.Millisecond
+	client := New(
+		OptionBaseURL(server.URL),
+		OptionHTTPClient(&http.Client{Transport: transport}),
+		OptionAuth(StaticToken("token")),
+	)
+	return client, calls
+}
+
+func TestRetryGetWithRetryAfter(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		client, calls := failingServer(t, 2, status, "0")
+
+		_, err := client.GetApplication(context.Background(), "env", "org", "app")
+		if err != nil {
+			t.Errorf("%d: GetApplication = %v, want success after retries", status, err)
+		}
+		if got := calls.Load(); got != 3 {
+			t.Errorf("%d: server got %d calls, want 3", status, got)
+		}
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	client, calls := failingServer(t, 10, http.StatusServiceUnavailable, "0")
+
+	_, err := client.GetApplication(context.Background(), "env", "org", "app")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("GetApplication = %v, want a 503 APIError", err)
+	}
+	if got := calls.Load(); got != 4 {
+		t.Errorf("server got %d calls, want 4", got)
+	}
+}
+
+func TestRetryNeverRetriesChanges(t *testing.T) {
+	calls := []struct {
+		method string
+		call   func(*Client) error
+	}{
+		{http.MethodPost, func(c *Client) error {
+			_, err := c.ChangeAppStatus(context.Background(), "stop", "env", "org", "app")
+			return err
+		}},
+		{http.MethodPut, func(c *Client) error {
+			return c.ScaleApp(context.Background(), "env", "org", "app", 2)
+		}},
+	}
+	for _, tc := range calls {
+		client, count := failingServer(t, 1, http.StatusServiceUnavailable, "0")
+
+		err := tc.call(client)
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("%s: got %v, want a 503 APIError", tc.method, err)
+		}
+		if got := count.Load(); got != 1 {
+			t.Errorf("%s: server got %d calls, want 1", tc.method, got)
+		}
+	}
+}
+
+func TestRetryAfterAboveMaxIsNotRetried(t *testing.T) {
+	client, calls := failingServer(t, 1, http.StatusTooManyRequests, "60")
+
+	start := time.Now()
+	_, err := client.GetApplication(context.Background(), "env", "org", "app")
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("GetApplication = %v, want %v", err, ErrRateLimited)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("server got %d calls, want 1", got)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("GetApplication waited %v for a Retry-After above the limit", elapsed)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"0", 0, true},
+		{"5", 5 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, true},
+	}
+	for _, tc := range tests {
+		got, ok := parseRetryAfter(tc.value)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tc.value, got, ok, tc.want, tc.ok)
+		}
+	}
+}
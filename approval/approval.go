@@ -0,0 +1,226 @@
+package approval
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jchawla2804/golang-slack-event-listener/database"
+)
+
+var (
+	ErrNotFound     = errors.New("approval request not found")
+	ErrSelfApproval = errors.New("an operation cannot be approved by the user who requested it")
+	ErrNotPending   = errors.New("approval request has already been decided")
+	ErrExpired      = errors.New("approval request has expired")
+)
+
+// State is where an approval request is in its life cycle.
+type State string
+
+const (
+	Pending  State = "pending"
+	Approved State = "approved"
+	Rejected State = "rejected"
+	Expired  State = "expired"
+	Executed State = "executed"
+	Failed   State = "failed"
+)
+
+// Operation kinds that can be put up for approval.
+const (
+	ChangeStatus = "change-status"
+	Scale        = "scale"
+	SetProperty  = "set-property"
+)
+
+// retention is how long decided requests are kept after they expire.
+const retention = 7 * 24 * time.Hour
+
+// Operation is a production change waiting for a second person to approve it.
+type Operation struct {
+	Kind              string            `json:"kind"`
+	Params            map[string]string `json:"params"`
+	TeamID            string            `json:"teamId"`
+	BusinessGroupID   string            `json:"businessGroupId"`
+	BusinessGroupName string            `json:"businessGroupName,omitempty"`
+	EnvName           string            `json:"envName"`
+	EnvID             string            `json:"envId"`
+	AppName           string            `json:"appName"`
+}
+
+// Request is an operation together with who asked for it and who decided on it.
+type Request struct {
+	ID string `json:"id"`
+	Operation
+	RequestedBy string    `json:"requestedBy"`
+	RequestedAt time.Time `json:"requestedAt"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	State       State     `json:"state"`
+	DecidedBy   string    `json:"decidedBy,omitempty"`
+	DecidedAt   time.Time `json:"decidedAt,omitempty"`
+	Result      string    `json:"result,omitempty"`
+
+	// Where the request was made, so the requester hears about the outcome.
+	ReplyChannelID string `json:"replyChannelId,omitempty"`
+	ReplyThreadTS  string `json:"replyThreadTs,omitempty"`
+
+	// The message in the approvers channel that holds the Approve and Reject buttons.
+	ApproversChannelID string `json:"approversChannelId,omitempty"`
+	MessageTS          string `json:"messageTs,omitempty"`
+}
+
+// Store persists approval requests and makes sure each one is decided exactly once.
+type Store struct {
+	store  database.Store
+	window time.Duration
+	mu     sync.Mutex
+}
+
+// NewStore creates an approval store whose requests expire after window.
+func NewStore(store database.Store, window time.Duration) *Store {
+	return &Store{store: store, window: window}
+}
+
+func key(id string) string {
+	return "approval:" + id
+}
+
+// SetWindow changes how long new requests stay open. Existing requests keep their expiry.
+func (s *Store) SetWindow(window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.window = window
+}
+
+// Create saves a new pending request for the operation.
+// It returns the request with its ID and expiry filled in and an error if any.
+func (s *Store) Create(op Operation, requestedBy string) (*Request, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	window := s.window
+	s.mu.Unlock()
+
+	now := time.Now()
+	req := &Request{
+		ID:          hex.EncodeToString(id),
+		Operation:   op,
+		RequestedBy: requestedBy,
+		RequestedAt: now,
+		ExpiresAt:   now.Add(window),
+		State:       Pending,
+	}
+	return req, s.Save(req)
+}
+
+// Get returns a request. Pending requests past their expiry are reported as expired.
+func (s *Store) Get(id string) (*Request, error) {
+	value, found, err := s.store.Get(key(id))
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrNotFound
+	}
+
+	req := &Request{}
+	err = json.Unmarshal(value, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.State == Pending && time.Now().After(req.ExpiresAt) {
+		req.State = Expired
+	}
+	return req, nil
+}
+
+// Save stores the request until some time after it expires.
+func (s *Store) Save(req *Request) error {
+	value, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(key(req.ID), value, time.Until(req.ExpiresAt)+retention)
+}
+
+// Decide approves or rejects a pending request on behalf of decidedBy.
+// It returns ErrSelfApproval when the requester tries to approve their own request,
+// and ErrExpired or ErrNotPending when the request can no longer be decided.
+func (s *Store) Decide(id, decidedBy string, approve bool) (*Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	switch req.State {
+	case Pending:
+	case Expired:
+		return req, ErrExpired
+	default:
+		return req, ErrNotPending
+	}
+	if approve && decidedBy == req.RequestedBy {
+		return req, ErrSelfApproval
+	}
+
+	req.State = Rejected
+	if approve {
+		req.State = Approved
+	}
+	req.DecidedBy = decidedBy
+	req.DecidedAt = time.Now()
+	return req, s.Save(req)
+}
+
+// Finish records the outcome of executing an approved request.
+func (s *Store) Finish(req *Request, err error) error {
+	req.State = Executed
+	req.Result = "ok"
+	if err != nil {
+		req.State = Failed
+		req.Result = err.Error()
+	}
+	return s.Save(req)
+}
+
+// ExpirePending marks every pending request past its expiry as expired.
+// It returns the requests that just expired so their messages can be updated.
+func (s *Store) ExpirePending() ([]*Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.store.Keys(key(""))
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []*Request
+	for _, k := range keys {
+		req, err := s.Get(k[len(key("")):])
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return expired, err
+		}
+		if req.State != Expired || req.DecidedAt.After(req.ExpiresAt) {
+			continue
+		}
+		// DecidedAt marks that the expiry has been handled.
+		req.DecidedAt = time.Now()
+		if err := s.Save(req); err != nil {
+			return expired, err
+		}
+		expired = append(expired, req)
+	}
+	return expired, nil
+}
@@ -0,0 +1,186 @@
+package audit
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jchawla2804/golang-slack-event-listener/database"
+)
+
+// Results of an audited action.
+const (
+	ResultOK     = "ok"
+	ResultError  = "error"
+	ResultDenied = "denied"
+)
+
+const (
+	keyPrefix = "audit:"
+	// keysEnd sorts right after every key that starts with keyPrefix.
+	keysEnd = "audit;"
+)
+
+// Entry is one audited command or state-changing action.
+type Entry struct {
+	ID              string        `json:"id"`
+	Time            time.Time     `json:"time"`
+	TeamID          string        `json:"teamId"`
+	UserID          string        `json:"userId"`
+	Action          string        `json:"action"`
+	Command         string        `json:"command,omitempty"`
+	Text            string        `json:"text,omitempty"`
+	BusinessGroupID string        `json:"businessGroupId,omitempty"`
+	EnvName         string        `json:"envName,omitempty"`
+	EnvID           string        `json:"envId,omitempty"`
+	AppName         string        `json:"appName,omitempty"`
+	Result          string        `json:"result"`
+	Error           string        `json:"error,omitempty"`
+	Latency         time.Duration `json:"latency"`
+	RequestIDs      []string      `json:"requestIds,omitempty"`
+}
+
+// Query selects entries. Empty fields match everything.
+type Query struct {
+	TeamID  string
+	UserID  string
+	AppName string
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+}
+
+// Log is an append-only audit log. Every entry is written as a JSON line to a file
+// and kept in a store so recent entries can be searched.
+type Log struct {
+	mu        sync.Mutex
+	file      *os.File
+	store     database.Store
+	retention time.Duration
+}
+
+// Open opens the audit file for appending, creating it if needed.
+// Entries stay searchable in store for retention.
+func Open(path string, store database.Store, retention time.Duration) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &Log{file: file, store: store, retention: retention}, nil
+}
+
+// Record appends an entry. The ID and time are filled in when empty.
+func (l *Log) Record(entry Entry) error {
+	if entry.ID == "" {
+		id := make([]byte, 6)
+		if _, err := rand.Read(id); err != nil {
+			return err
+		}
+		entry.ID = hex.EncodeToString(id)
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, err = l.file.Write(append(line, '\n'))
+	if err != nil {
+		return err
+	}
+	// Keys sort by time, so a search can walk them in order.
+	key := timeKey(entry.Time) + ":" + entry.ID
+	return l.store.Set(key, line, l.retention)
+}
+
+// Search returns the newest entries matching the query, newest first.
+// Only the keys in the time range of the query are listed, and entries are read until the limit is reached.
+func (l *Log) Search(query Query) ([]Entry, error) {
+	start, end := keyPrefix, keysEnd
+	if !query.Since.IsZero() {
+		start = timeKey(query.Since)
+	}
+	if !query.Until.IsZero() {
+		end = timeKey(query.Until.Add(time.Nanosecond))
+	}
+	keys, err := l.store.KeyRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for i := len(keys) - 1; i >= 0; i-- {
+		if query.Limit > 0 && len(entries) >= query.Limit {
+			break
+		}
+
+		value, found, err := l.store.Get(keys[i])
+		if err != nil {
+			return entries, err
+		}
+		if !found {
+			continue
+		}
+		entry := Entry{}
+		if err := json.Unmarshal(value, &entry); err != nil {
+			return entries, err
+		}
+		if query.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// timeKey is the start of the keys of entries recorded at t.
+func timeKey(t time.Time) string {
+	return fmt.Sprintf("%s%020d", keyPrefix, t.UnixNano())
+}
+
+// Sync flushes the audit file to disk.
+func (l *Log) Sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Sync()
+}
+
+// Close flushes and closes the audit file.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.file.Sync(); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
+
+func (q Query) matches(entry Entry) bool {
+	if q.TeamID != "" && entry.TeamID != q.TeamID {
+		return false
+	}
+	if q.UserID != "" && entry.UserID != q.UserID {
+		return false
+	}
+	if q.AppName != "" && !strings.EqualFold(entry.AppName, q.AppName) {
+		return false
+	}
+	if !q.Since.IsZero() && entry.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && entry.Time.After(q.Until) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jchawla2804/golang-slack-event-listener/database"
+)
+
+// countingStore counts the values read from the store it wraps.
+type countingStore struct {
+	database.Store
+	gets int
+}
+
+func (s *countingStore) Get(key string) ([]byte, bool, error) {
+	s.gets++
+	return s.Store.Get(key)
+}
+
+func TestSearchReadsOnlyTheRequestedRange(t *testing.T) {
+	store := &countingStore{Store: database.NewMemoryStore()}
+	log, err := Open(filepath.Join(t.TempDir(), "audit.log"), store, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i := 0; i < 10; i++ {
+		err := log.Record(Entry{ID: string(rune('a' + i)), Time: base.Add(time.Duration(i) * time.Minute), UserID: "U1", Action: "command"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+		gets  int
+	}{
+		{name: "everything", query: Query{}, want: []string{"j", "i", "h", "g", "f", "e", "d", "c", "b", "a"}, gets: 10},
+		{name: "limit", query: Query{Limit: 3}, want: []string{"j", "i", "h"}, gets: 3},
+		{name: "since", query: Query{Since: base.Add(7 * time.Minute)}, want: []string{"j", "i", "h"}, gets: 3},
+		{name: "until", query: Query{Until: base.Add(2 * time.Minute)}, want: []string{"c", "b", "a"}, gets: 3},
+		{name: "range with limit", query: Query{Since: base.Add(2 * time.Minute), Until: base.Add(6 * time.Minute), Limit: 2}, want: []string{"g", "f"}, gets: 2},
+		{name: "other user", query: Query{UserID: "U2", Since: base.Add(8 * time.Minute)}, want: nil, gets: 2},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store.gets = 0
+			entries, err := log.Search(tc.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, entry := range entries {
+				ids = append(ids, entry.ID)
+			}
+			if !slices.Equal(ids, tc.want) {
+				t.Errorf("Search = %v, want %v", ids, tc.want)
+			}
+			if store.gets != tc.gets {
+				t.Errorf("Search read %d entries, want %d", store.gets, tc.gets)
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jchawla2804/golang-slack-event-listener/logging"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting of the bot. Settings come from the defaults, then the
+// config file, then the environment variable named in the env tag of each field.
+// Settings tagged reload:"live" take effect when the configuration is reloaded;
+// changing any other setting needs a restart.
+type Config struct {
+	Log   Log   `yaml:"log"`
+	Slack Slack `yaml:"slack"`
+	// Workspaces connects the bot to several Slack workspaces. When it is empty the bot
+	// connects to the one workspace configured by Slack, Approvals.ChannelID and Anypoint.OrgID.
+	Workspaces   []Workspace  `yaml:"workspaces"`
+	Anypoint     Anypoint     `yaml:"anypoint"`
+	Environments Environments `yaml:"environments"`
+	Store        Store        `yaml:"store"`
+	Audit        Audit        `yaml:"audit"`
+	Approvals    Approvals    `yaml:"approvals"`
+	RBAC         RBAC         `yaml:"rbac"`
+	Metrics      Metrics      `yaml:"metrics"`
+	Dispatcher   Dispatcher   `yaml:"dispatcher"`
+	// ShutdownTimeout is how long running requests may take to finish on shutdown.
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout" env:"SHUTDOWN_TIMEOUT"`
+}
+
+type Log struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" reload:"live"`
+	Format string `yaml:"format" env:"LOG_FORMAT"`
+}
+
+type Slack struct {
+	// Mode is socket for Socket Mode or http for Events API requests over HTTP.
+	Mode          string `yaml:"mode" env:"SLACK_MODE"`
+	BotToken      string `yaml:"botToken" env:"SLACK_BOT_TOKEN" secret:"true"`
+	AppToken      string `yaml:"appToken" env:"SLACK_APP_TOKEN" secret:"true"`
+	SigningSecret string `yaml:"signingSecret" env:"SLACK_SIGNING_SECRET" secret:"true"`
+	HTTPAddr      string `yaml:"httpAddr" env:"HTTP_ADDR"`
+	// AdminChannelID is where configuration reloads are announced. Nothing is posted when it is empty.
+	AdminChannelID string `yaml:"adminChannelId" env:"ADMIN_CHANNEL_ID" reload:"live"`
+}
+
+// Workspace is a Slack workspace the bot connects to.
+type Workspace struct {
+	Name string `yaml:"name"`
+	// TeamID is looked up with the bot token when it is empty.
+	TeamID        string `yaml:"teamId"`
+	BotToken      string `yaml:"botToken" secret:"true"`
+	AppToken      string `yaml:"appToken" secret:"true"`
+	SigningSecret string `yaml:"signingSecret" secret:"true"`
+	// DefaultChannelID is where production changes are sent for approval. It is required while
+	// approvals.required is on; otherwise approvals are off when it is empty.
+	DefaultChannelID string `yaml:"defaultChannelId" reload:"live"`
+	// AdminChannelID is where configuration reloads are announced.
+	AdminChannelID string `yaml:"adminChannelId" reload:"live"`
+	// OrgID is the Anypoint organization whose Exchange assets /get-asset-info lists for logins to it.
+	OrgID string `yaml:"orgId" reload:"live"`
+}
+
+type Anypoint struct {
+	// OrgID is the organization whose Exchange assets /get-asset-info lists for logins to it.
+	// The business group of the user is used when it is empty.
+	OrgID string `yaml:"orgId" env:"ANYPOINT_ORG_ID" reload:"live"`
+	// ConnectedAppLifetime caps how long connected app logins re-mint their tokens. With 0 they
+	// do so until the user logs out or Anypoint rejects the client credentials.
+	ConnectedAppLifetime time.Duration `yaml:"connectedAppLifetime" env:"CONNECTED_APP_LIFETIME" reload:"live"`
+}
+
+type Environments struct {
+	// Aliases maps short names such as dev or prod to environment names, per business group
+	// id or name. Aliases under "*" apply to every business group.
+	Aliases map[string]map[string]string `yaml:"aliases" reload:"live"`
+}
+
+type Store struct {
+	// Type is memory or bolt.
+	Type          string `yaml:"type" env:"STORE_TYPE"`
+	Path          string `yaml:"path" env:"STORE_PATH"`
+	MasterKey     string `yaml:"masterKey" env:"STORE_MASTER_KEY" secret:"true"`
+	MasterKeyFile string `yaml:"masterKeyFile" env:"STORE_MASTER_KEY_FILE"`
+}
+
+type Audit struct {
+	File      string        `yaml:"file" env:"AUDIT_LOG_FILE"`
+	Retention time.Duration `yaml:"retention" env:"AUDIT_RETENTION"`
+}
+
+type Approvals struct {
+	// Required makes every production change need a second approver, so each workspace needs an
+	// approvers channel. When it is off, approvals only apply where a channel is configured.
+	Required bool `yaml:"required" env:"APPROVALS_REQUIRED" reload:"live"`
+	// ChannelID is where production changes are sent for approval.
+	ChannelID string        `yaml:"channelId" env:"APPROVERS_CHANNEL_ID" reload:"live"`
+	Window    time.Duration `yaml:"window" env:"APPROVAL_WINDOW" reload:"live"`
+}
+
+type RBAC struct {
+	// PolicyFile is reloaded whenever it changes.
+	PolicyFile string `yaml:"policyFile" env:"RBAC_POLICY_FILE" reload:"live"`
+}
+
+type Metrics struct {
+	// Addr is where /metrics, /healthz and /readyz are served. They are off when it is empty.
+	Addr string `yaml:"addr" env:"METRICS_ADDR"`
+}
+
+type Dispatcher struct {
+	Workers   int `yaml:"workers" env:"WORKERS"`
+	QueueSize int `yaml:"queueSize" env:"QUEUE_SIZE"`
+	// MaxPending caps the events of all users waiting for a worker.
+	MaxPending     int           `yaml:"maxPending" env:"MAX_PENDING"`
+	HandlerTimeout time.Duration `yaml:"handlerTimeout" env:"HANDLER_TIMEOUT"`
+}
+
+// Default returns the configuration used for everything the file and environment leave out.
+func Default() *Config {
+	return &Config{
+		Log:   Log{Level: "info", Format: "text"},
+		Slack: Slack{Mode: "socket", HTTPAddr: ":3000"},
+		Store: Store{Type: "memory", Path: "slack-bot.db"},
+		Audit: Audit{File: "audit.log", Retention: 90 * 24 * time.Hour},
+		Approvals: Approvals{
+			Required: true,
+			Window:   time.Hour,
+		},
+		Dispatcher: Dispatcher{
+			Workers:        8,
+			QueueSize:      16,
+			MaxPending:     256,
+			HandlerTimeout: 5 * time.Minute,
+		},
+		ShutdownTimeout: 30 * time.Second,
+	}
+}
+
+// EffectiveWorkspaces returns the workspaces to connect to: the configured workspaces,
+// or a single one named default built from the slack, approvals and anypoint settings.
+func (c *Config) EffectiveWorkspaces() []Workspace {
+	if len(c.Workspaces) > 0 {
+		return c.Workspaces
+	}
+	return []Workspace{{
+		Name:             "default",
+		BotToken:         c.Slack.BotToken,
+		AppToken:         c.Slack.AppToken,
+		SigningSecret:    c.Slack.SigningSecret,
+		DefaultChannelID: c.Approvals.ChannelID,
+		AdminChannelID:   c.Slack.AdminChannelID,
+		OrgID:            c.Anypoint.OrgID,
+	}}
+}
+
+// Load reads the configuration from the YAML file at path, if path is not empty,
+// applies the environment variable overrides and validates the result.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+		decoder.KnownFields(true)
+		err = decoder.Decode(cfg)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+
+	err := applyEnv(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate checks every setting and returns all problems at once.
+func (c *Config) Validate() error {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		problem("log.level (LOG_LEVEL): %s", err.Error())
+	}
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		problem("log.format (LOG_FORMAT) must be text or json, not %q", c.Log.Format)
+	}
+
+	switch c.Slack.Mode {
+	case "socket":
+		if len(c.Workspaces) == 0 && c.Slack.AppToken == "" {
+			problem("slack.appToken (SLACK_APP_TOKEN) is required in socket mode")
+		}
+	case "http":
+		if len(c.Workspaces) == 0 && c.Slack.SigningSecret == "" {
+			problem("slack.signingSecret (SLACK_SIGNING_SECRET) is required in http mode")
+		}
+		if c.Slack.HTTPAddr == "" {
+			problem("slack.httpAddr (HTTP_ADDR) is required in http mode")
+		}
+	default:
+		problem("slack.mode (SLACK_MODE) must be socket or http, not %q", c.Slack.Mode)
+	}
+	if len(c.Workspaces) == 0 && c.Slack.BotToken == "" {
+		problem("slack.botToken (SLACK_BOT_TOKEN) is required")
+	}
+
+	names := map[string]bool{}
+	teams := map[string]bool{}
+	for i, ws := range c.Workspaces {
+		setting := fmt.Sprintf("workspaces[%d]", i)
+		switch {
+		case ws.Name == "":
+			problem("%s.name is required", setting)
+		case names[ws.Name]:
+			problem("%s.name %q is used twice", setting, ws.Name)
+		}
+		names[ws.Name] = true
+		if ws.TeamID != "" && teams[ws.TeamID] {
+			problem("%s.teamId %q is used twice", setting, ws.TeamID)
+		}
+		teams[ws.TeamID] = true
+		if ws.BotToken == "" {
+			problem("%s.botToken is required", setting)
+		}
+		if c.Slack.Mode == "socket" && ws.AppToken == "" {
+			problem("%s.appToken is required in socket mode", setting)
+		}
+		if c.Slack.Mode == "http" && ws.SigningSecret == "" {
+			problem("%s.signingSecret is required in http mode", setting)
+		}
+		if c.Approvals.Required && ws.DefaultChannelID == "" {
+			problem("%s.defaultChannelId is required while approvals.required (APPROVALS_REQUIRED) is on", setting)
+		}
+	}
+
+	for group, aliases := range c.Environments.Aliases {
+		for alias, name := range aliases {
+			if strings.TrimSpace(alias) == "" || strings.TrimSpace(name) == "" {
+				problem("environments.aliases.%s: aliases and environment names must not be empty", group)
+			}
+		}
+	}
+
+	switch c.Store.Type {
+	case "memory":
+	case "bolt":
+		if c.Store.MasterKey == "" && c.Store.MasterKeyFile == "" {
+			problem("store.masterKey (STORE_MASTER_KEY) or store.masterKeyFile (STORE_MASTER_KEY_FILE) is required for the bolt store")
+		}
+	default:
+		problem("store.type (STORE_TYPE) must be memory or bolt, not %q", c.Store.Type)
+	}
+
+	if c.Audit.File == "" {
+		problem("audit.file (AUDIT_LOG_FILE) is required")
+	}
+	if c.Audit.Retention <= 0 {
+		problem("audit.retention (AUDIT_RETENTION) must be positive")
+	}
+	if c.Anypoint.ConnectedAppLifetime < 0 {
+		problem("anypoint.connectedAppLifetime (CONNECTED_APP_LIFETIME) must not be negative")
+	}
+	if c.Approvals.Required && len(c.Workspaces) == 0 && c.Approvals.ChannelID == "" {
+		problem("approvals.channelId (APPROVERS_CHANNEL_ID) is required while approvals.required (APPROVALS_REQUIRED) is on")
+	}
+	if c.Approvals.Window <= 0 {
+		problem("approvals.window (APPROVAL_WINDOW) must be positive")
+	}
+	if c.Dispatcher.Workers < 1 {
+		problem("dispatcher.workers (WORKERS) must be at least 1")
+	}
+	if c.Dispatcher.QueueSize < 0 {
+		problem("dispatcher.queueSize (QUEUE_SIZE) must not be negative")
+	}
+	if c.Dispatcher.MaxPending < 1 {
+		problem("dispatcher.maxPending (MAX_PENDING) must be at least 1")
+	}
+	if c.Dispatcher.HandlerTimeout <= 0 {
+		problem("dispatcher.handlerTimeout (HANDLER_TIMEOUT) must be positive")
+	}
+	if c.ShutdownTimeout <= 0 {
+		problem("shutdownTimeout (SHUTDOWN_TIMEOUT) must be positive")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig is the default configuration with the settings that have no default filled in.
+func validConfig() *Config {
+	cfg := Default()
+	cfg.Slack.BotToken = "xoxb-bot"
+	cfg.Slack.AppToken = "xapp-app"
+	cfg.Approvals.ChannelID = "C-APPROVERS"
+	return cfg
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDefaults(t *testing.T) {
+	cfg := Default()
+	if cfg.Slack.Mode != "socket" || cfg.Store.Type != "memory" || cfg.Log.Level != "info" {
+		t.Errorf("defaults = mode %q, store %q, log level %q; want socket, memory, info", cfg.Slack.Mode, cfg.Store.Type, cfg.Log.Level)
+	}
+	if !cfg.Approvals.Required || cfg.Approvals.Window != time.Hour {
+		t.Errorf("default approvals = %+v, want required with a 1h window", cfg.Approvals)
+	}
+	if err := validConfig().Validate(); err != nil {
+		t.Errorf("defaults with tokens and an approvers channel: %v", err)
+	}
+
+	workspaces := validConfig().EffectiveWorkspaces()
+	if len(workspaces) != 1 || workspaces[0].Name != "default" || workspaces[0].BotToken != "xoxb-bot" || workspaces[0].DefaultChannelID != "C-APPROVERS" {
+		t.Errorf("EffectiveWorkspaces = %+v, want the default workspace built from the slack and approvals settings", workspaces)
+	}
+}
+
+func TestLoadFileThenEnvironment(t *testing.T) {
+	path := writeConfig(t, `
+log:
+  level: debug
+slack:
+  botToken: xoxb-from-file
+  appToken: xapp-from-file
+approvals:
+  channelId: C-FILE
+  window: 2h
+dispatcher:
+  workers: 4
+`)
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("SLACK_BOT_TOKEN", "xoxb-from-env")
+	t.Setenv("APPROVALS_REQUIRED", "false")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		setting string
+		got     interface{}
+		want    interface{}
+	}{
+		{"log.level from the environment", cfg.Log.Level, "warn"},
+		{"slack.botToken from the environment", cfg.Slack.BotToken, "xoxb-from-env"},
+		{"approvals.required from the environment", cfg.Approvals.Required, false},
+		{"slack.appToken from the file", cfg.Slack.AppToken, "xapp-from-file"},
+		{"approvals.window from the file", cfg.Approvals.Window, 2 * time.Hour},
+		{"dispatcher.workers from the file", cfg.Dispatcher.Workers, 4},
+		{"dispatcher.queueSize by default", cfg.Dispatcher.QueueSize, 16},
+		{"log.format by default", cfg.Log.Format, "text"},
+	}
+	for _, tc := range tests {
+		if tc.got != tc.want {
+			t.Errorf("%s = %v, want %v", tc.setting, tc.got, tc.want)
+		}
+	}
+}
+
+func TestLoadRejectsUnknownSettings(t *testing.T) {
+	path := writeConfig(t, `
+slack:
+  botTokn: xoxb-typo
+`)
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), "botTokn") {
+		t.Errorf("Load = %v, want an error naming botTokn", err)
+	}
+}
+
+func TestLoadRejectsInvalidEnvironment(t *testing.T) {
+	tests := []struct {
+		env, value, want string
+	}{
+		{"WORKERS", "many", "invalid WORKERS"},
+		{"APPROVAL_WINDOW", "an hour", "invalid APPROVAL_WINDOW"},
+		{"APPROVALS_REQUIRED", "maybe", "invalid APPROVALS_REQUIRED"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.env, func(t *testing.T) {
+			t.Setenv(tc.env, tc.value)
+			_, err := Load("")
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Load with %s=%q = %v, want %q", tc.env, tc.value, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	cfg := validConfig()
+	cfg.Log.Format = "xml"
+	cfg.Slack.AppToken = ""
+	cfg.Store.Type = "bolt"
+	cfg.Approvals.ChannelID = ""
+	cfg.Dispatcher.Workers = 0
+	cfg.Workspaces = nil
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate accepted an invalid configuration")
+	}
+	for _, want := range []string{
+		`log.format (LOG_FORMAT) must be text or json, not "xml"`,
+		"slack.appToken (SLACK_APP_TOKEN) is required in socket mode",
+		"store.masterKey (STORE_MASTER_KEY) or store.masterKeyFile (STORE_MASTER_KEY_FILE) is required",
+		"approvals.channelId (APPROVERS_CHANNEL_ID) is required while approvals.required (APPROVALS_REQUIRED) is on",
+		"dispatcher.workers (WORKERS) must be at least 1",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate = %v\nwant it to contain %q", err, want)
+		}
+	}
+}
+
+func TestValidateWorkspaces(t *testing.T) {
+	cfg := validConfig()
+	cfg.Workspaces = []Workspace{
+		{Name: "one", TeamID: "T1", BotToken: "xoxb-1", AppToken: "xapp-1", DefaultChannelID: "C1"},
+		{Name: "one", TeamID: "T1", BotToken: "xoxb-2", AppToken: "xapp-2"},
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate accepted duplicate workspaces")
+	}
+	for _, want := range []string{
+		`workspaces[1].name "one" is used twice`,
+		`workspaces[1].teamId "T1" is used twice`,
+		"workspaces[1].defaultChannelId is required",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate = %v\nwant it to contain %q", err, want)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := validConfig()
+	next := validConfig()
+	next.Log.Level = "debug"
+	next.Approvals.Window = 2 * time.Hour
+	next.Slack.Mode = "http"
+	next.Slack.BotToken = "xoxb-rotated"
+
+	changes := Diff(old, next)
+	want := map[string]Change{
+		"log.level":        {Setting: "log.level", Old: "info", New: "debug"},
+		"approvals.window": {Setting: "approvals.window", Old: "1h0m0s", New: "2h0m0s"},
+		"slack.mode":       {Setting: "slack.mode", Old: "socket", New: "http", NeedsRestart: true},
+		"slack.botToken":   {Setting: "slack.botToken", Old: masked, New: masked, NeedsRestart: true},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Diff = %+v, want %d changes", changes, len(want))
+	}
+	for _, change := range changes {
+		if change != want[change.Setting] {
+			t.Errorf("change = %+v, want %+v", change, want[change.Setting])
+		}
+	}
+	if !NeedsRestart(changes) {
+		t.Error("NeedsRestart = false with a changed slack.mode")
+	}
+}
+
+func TestDiffLiveOnly(t *testing.T) {
+	old := validConfig()
+	next := validConfig()
+	next.Slack.AdminChannelID = "C-ADMIN"
+	next.Environments.Aliases = map[string]map[string]string{"*": {"prod": "Production"}}
+
+	changes := Diff(old, next)
+	if len(changes) != 2 {
+		t.Errorf("Diff = %+v, want 2 changes", changes)
+	}
+	if NeedsRestart(changes) {
+		t.Errorf("NeedsRestart = true for live settings: %+v", changes)
+	}
+	if len(Diff(old, validConfig())) != 0 {
+		t.Error("Diff of equal configurations is not empty")
+	}
+}
+
+func TestDiffWorkspaces(t *testing.T) {
+	workspaces := func() []Workspace {
+		return []Workspace{
+			{Name: "a", TeamID: "T1", BotToken: "xoxb-1", AppToken: "xapp-1", DefaultChannelID: "C1"},
+			{Name: "b", TeamID: "T2", BotToken: "xoxb-2", AppToken: "xapp-2", DefaultChannelID: "C2"},
+		}
+	}
+	tests := []struct {
+		name   string
+		change func(cfg *Config)
+		want   []Change
+	}{
+		{
+			name:   "removed",
+			change: func(cfg *Config) { cfg.Workspaces = cfg.Workspaces[1:] },
+			want:   []Change{{Setting: "workspaces[a]", Old: "configured", New: "removed", NeedsRestart: true}},
+		},
+		{
+			name: "added",
+			change: func(cfg *Config) {
+				cfg.Workspaces = append(cfg.Workspaces, Workspace{Name: "c", TeamID: "T3", BotToken: "xoxb-3", AppToken: "xapp-3"})
+			},
+			want: []Change{{Setting: "workspaces[c]", New: "added", NeedsRestart: true}},
+		},
+		{
+			name:   "signing secret",
+			change: func(cfg *Config) { cfg.Workspaces[1].SigningSecret = "rotated" },
+			want:   []Change{{Setting: "workspaces[b].signingSecret", New: masked, NeedsRestart: true}},
+		},
+		{
+			name: "reordered with a live change",
+			change: func(cfg *Config) {
+				cfg.Workspaces[0], cfg.Workspaces[1] = cfg.Workspaces[1], cfg.Workspaces[0]
+				cfg.Workspaces[0].DefaultChannelID = "C3"
+			},
+			want: []Change{{Setting: "workspaces[b].defaultChannelId", Old: "C2", New: "C3"}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			old := validConfig()
+			old.Workspaces = workspaces()
+			next := validConfig()
+			next.Workspaces = workspaces()
+			tc.change(next)
+
+			changes := Diff(old, next)
+			if !reflect.DeepEqual(changes, tc.want) {
+				t.Errorf("Diff = %+v, want %+v", changes, tc.want)
+			}
+		})
+	}
+}
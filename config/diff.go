@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Change is a setting that differs between two configurations.
+type Change struct {
+	Setting string
+	Old     string
+	New     string
+	// NeedsRestart is set for settings that only take effect when the bot starts.
+	NeedsRestart bool
+}
+
+// Diff lists the settings that differ between old and next. Secrets are masked.
+// Workspaces are compared by name, as the registry applies their settings by name;
+// adding or removing one always needs a restart.
+func Diff(old, next *Config) []Change {
+	oldCopy, nextCopy := *old, *next
+	oldCopy.Workspaces, nextCopy.Workspaces = nil, nil
+	changes := diffSettings(reflect.ValueOf(&oldCopy).Elem(), reflect.ValueOf(&nextCopy).Elem(), "")
+	return append(changes, diffWorkspaces(old.Workspaces, next.Workspaces)...)
+}
+
+// diffWorkspaces lists the workspaces added or removed and the settings that changed in the others.
+func diffWorkspaces(old, next []Workspace) []Change {
+	previous := map[string]Workspace{}
+	for _, ws := range old {
+		previous[ws.Name] = ws
+	}
+
+	var changes []Change
+	for _, ws := range next {
+		setting := fmt.Sprintf("workspaces[%s]", ws.Name)
+		before, found := previous[ws.Name]
+		delete(previous, ws.Name)
+		if !found {
+			changes = append(changes, Change{Setting: setting, New: "added", NeedsRestart: true})
+			continue
+		}
+		changes = append(changes, diffSettings(reflect.ValueOf(&before).Elem(), reflect.ValueOf(&ws).Elem(), setting+".")...)
+	}
+	for _, ws := range old {
+		if _, found := previous[ws.Name]; found {
+			changes = append(changes, Change{Setting: fmt.Sprintf("workspaces[%s]", ws.Name), Old: "configured", New: "removed", NeedsRestart: true})
+		}
+	}
+	return changes
+}
+
+// diffSettings lists the settings that differ between the structs old and next.
+func diffSettings(old, next reflect.Value, prefix string) []Change {
+	type setting struct {
+		field reflect.StructField
+		value reflect.Value
+	}
+	previous := map[string]setting{}
+	var order []string
+	walk(old, prefix, func(name string, field reflect.StructField, value reflect.Value) error {
+		previous[name] = setting{field, value}
+		order = append(order, name)
+		return nil
+	})
+
+	var changes []Change
+	walk(next, prefix, func(name string, field reflect.StructField, value reflect.Value) error {
+		before, found := previous[name]
+		delete(previous, name)
+		if !found {
+			if !value.IsZero() {
+				changes = append(changes, Change{Setting: name, New: display(field, value), NeedsRestart: true})
+			}
+			return nil
+		}
+		if reflect.DeepEqual(before.value.Interface(), value.Interface()) {
+			return nil
+		}
+		changes = append(changes, Change{
+			Setting:      name,
+			Old:          display(field, before.value),
+			New:          display(field, value),
+			NeedsRestart: field.Tag.Get("reload") != "live",
+		})
+		return nil
+	})
+	for _, name := range order {
+		if removed, found := previous[name]; found && !removed.value.IsZero() {
+			changes = append(changes, Change{Setting: name, Old: display(removed.field, removed.value), NeedsRestart: true})
+		}
+	}
+	return changes
+}
+
+// NeedsRestart reports whether any of the changes only takes effect after a restart.
+func NeedsRestart(changes []Change) bool {
+	for _, change := range changes {
+		if change.NeedsRestart {
+			return true
+		}
+	}
+	return false
+}
+
+// display formats a setting for people to read, with secrets masked.
+func display(field reflect.StructField, value reflect.Value) string {
+	if field.Tag.Get("secret") == "true" && value.String() != "" {
+		return masked
+	}
+	if value.Type() == durationType {
+		return value.Interface().(fmt.Stringer).String()
+	}
+	return fmt.Sprint(value.Interface())
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const masked = "********"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyEnv overrides every field that has an env tag with its environment variable, if set.
+func applyEnv(cfg *Config) error {
+	return walk(reflect.ValueOf(cfg).Elem(), "", func(_ string, field reflect.StructField, value reflect.Value) error {
+		name := field.Tag.Get("env")
+		if name == "" {
+			return nil
+		}
+		raw, found := os.LookupEnv(name)
+		if !found {
+			return nil
+		}
+
+		switch {
+		case value.Type() == durationType:
+			d, err := time.ParseDuration(raw)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			value.SetInt(int64(d))
+		case value.Kind() == reflect.Int:
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %q is not a number", name, raw)
+			}
+			value.SetInt(int64(n))
+		case value.Kind() == reflect.Bool:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %q is not true or false", name, raw)
+			}
+			value.SetBool(b)
+		default:
+			value.SetString(raw)
+		}
+		return nil
+	})
+}
+
+// walk calls fn for every field of v, descending into nested structs and slices of structs.
+// Each field is passed with its setting name, e.g. slack.botToken or workspaces[0].name, prefixed by prefix.
+func walk(v reflect.Value, prefix string, fn func(setting string, field reflect.StructField, value reflect.Value) error) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		value := v.Field(i)
+		setting := prefix + field.Tag.Get("yaml")
+		if value.Kind() == reflect.Struct && value.Type() != durationType {
+			if err := walk(value, setting+".", fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Struct {
+			for j := 0; j < value.Len(); j++ {
+				if err := walk(value.Index(j), fmt.Sprintf("%s[%d].", setting, j), fn); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := fn(setting, field, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Dump renders the configuration as YAML with every secret masked.
+func (c *Config) Dump() ([]byte, error) {
+	copied := *c
+	copied.Workspaces = append([]Workspace(nil), c.Workspaces...)
+	walk(reflect.ValueOf(&copied).Elem(), "", func(_ string, field reflect.StructField, value reflect.Value) error {
+		if field.Tag.Get("secret") == "true" && value.String() != "" {
+			value.SetString(masked)
+		}
+		return nil
+	})
+	return yaml.Marshal(&copied)
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// debounce is how long the watcher waits for writes to settle before reloading.
+// Editors often write a file in several steps.
+const debounce = 500 * time.Millisecond
+
+// ApplyFunc puts a reloaded configuration into effect. If it fails, the old configuration stays.
+// It returns the changes that are not settings of the configuration, such as the rules of the policy file.
+type ApplyFunc func(old, next *Config) ([]Change, error)
+
+// ReportFunc is told about every reload: what triggered it, the settings that changed
+// and, if the new configuration was rejected, why.
+type ReportFunc func(trigger string, changes []Change, err error)
+
+// Watcher reloads the configuration when the config file or the RBAC policy file
+// changes, or when the process receives SIGHUP.
+type Watcher struct {
+	path    string
+	current *Config
+	apply   ApplyFunc
+	report  ReportFunc
+}
+
+// NewWatcher creates a watcher for the config file at path, which may be empty to only
+// reload environment variables and the policy file. current is the configuration in effect.
+func NewWatcher(path string, current *Config, apply ApplyFunc, report ReportFunc) *Watcher {
+	return &Watcher{path: path, current: current, apply: apply, report: report}
+}
+
+// Run watches for changes until ctx is done.
+// SIGHUP is handled even when the files cannot be watched, as it would otherwise end the process.
+func (w *Watcher) Run(ctx context.Context) error {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	// Without a file watcher its channels stay nil and never deliver.
+	var fileEvents <-chan fsnotify.Event
+	var fileErrors <-chan error
+	files, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Warn("Could not watch configuration files, reloading on SIGHUP only", "err", err)
+	} else {
+		defer files.Close()
+		fileEvents, fileErrors = files.Events, files.Errors
+	}
+
+	watched := w.watch(files, nil)
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	trigger := ""
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-hangup:
+			timer.Stop()
+			trigger = ""
+			w.reload("SIGHUP")
+			watched = w.watch(files, watched)
+
+		case event, ok := <-fileEvents:
+			if !ok {
+				return nil
+			}
+			name, found := watched[filepath.Clean(event.Name)]
+			if !found || !event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+				continue
+			}
+			if trigger == "" {
+				trigger = name + " changed"
+			}
+			timer.Reset(debounce)
+
+		case <-timer.C:
+			w.reload(trigger)
+			trigger = ""
+			watched = w.watch(files, watched)
+
+		case err, ok := <-fileErrors:
+			if !ok {
+				return nil
+			}
+			slog.Warn("Error watching configuration files", "err", err)
+		}
+	}
+}
+
+// reload loads the configuration and applies it. The old configuration stays in effect
+// if the new one is invalid or cannot be applied.
+func (w *Watcher) reload(trigger string) {
+	slog.Info("Reloading configuration", "trigger", trigger)
+
+	var applied []Change
+	next, err := Load(w.path)
+	if err == nil {
+		applied, err = w.apply(w.current, next)
+	}
+	if err != nil {
+		slog.Error("Rejected reloaded configuration, keeping the current one", "trigger", trigger, "err", err)
+		w.report(trigger, nil, err)
+		return
+	}
+
+	changes := append(Diff(w.current, next), applied...)
+	w.current = next
+	for _, change := range changes {
+		slog.Info("Configuration setting changed", "setting", change.Setting, "old", change.Old, "new", change.New, "needs_restart", change.NeedsRestart)
+	}
+	w.report(trigger, changes, nil)
+}
+
+// watch watches the directories of the config file and the policy file, since editors
+// often replace a file instead of writing to it. It returns the watched files by path.
+// Without a file watcher it only returns the files.
+func (w *Watcher) watch(files *fsnotify.Watcher, previous map[string]string) map[string]string {
+	watched := map[string]string{}
+	if w.path != "" {
+		watched[filepath.Clean(w.path)] = "config file"
+	}
+	if w.current.RBAC.PolicyFile != "" {
+		watched[filepath.Clean(w.current.RBAC.PolicyFile)] = "RBAC policy file"
+	}
+	if files == nil {
+		return watched
+	}
+
+	dirs := map[string]bool{}
+	for path := range watched {
+		dirs[filepath.Dir(path)] = true
+	}
+	for path := range previous {
+		if dir := filepath.Dir(path); !dirs[dir] {
+			files.Remove(dir)
+		}
+	}
+	for dir := range dirs {
+		err := files.Add(dir)
+		if err != nil {
+			slog.Warn("Could not watch configuration directory", "dir", dir, "err", err)
+		}
+	}
+	return watched
+}
@@ -0,0 +1,186 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log/slog"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var bucketName = []byte("state")
+
+// BoltStore is a Store backed by a local BoltDB file, so state survives restarts.
+// Each value is prefixed with its expiry time; expired entries are hidden on read
+// and removed by a background compaction.
+type BoltStore struct {
+	db   *bolt.DB
+	stop chan struct{}
+	done chan struct{}
+}
+
+// OpenBoltStore opens or creates the database file at path.
+// It takes the path of the file and the interval between compactions as input parameters.
+// It returns the store and an error if any.
+func OpenBoltStore(path string, compactionInterval time.Duration) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketName)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	store := &BoltStore{db: db, stop: make(chan struct{}), done: make(chan struct{})}
+	go store.compactLoop(compactionInterval)
+	return store, nil
+}
+
+func (b *BoltStore) Get(key string) ([]byte, bool, error) {
+	var value []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		record := tx.Bucket(bucketName).Get([]byte(key))
+		if record == nil || expired(record, time.Now()) {
+			return nil
+		}
+		value = append([]byte{}, record[8:]...)
+		return nil
+	})
+	return value, value != nil, err
+}
+
+func (b *BoltStore) Set(key string, value []byte, ttl time.Duration) error {
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl).UnixNano()
+	}
+
+	record := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(record, uint64(expiresAt))
+	copy(record[8:], value)
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).Put([]byte(key), record)
+	})
+}
+
+func (b *BoltStore) TTL(key string) (time.Duration, bool, error) {
+	var ttl time.Duration
+	found := false
+	err := b.db.View(func(tx *bolt.Tx) error {
+		record := tx.Bucket(bucketName).Get([]byte(key))
+		if record == nil || expired(record, time.Now()) {
+			return nil
+		}
+		found = true
+		if expiresAt := int64(binary.BigEndian.Uint64(record)); expiresAt != 0 {
+			ttl = time.Until(time.Unix(0, expiresAt))
+		}
+		return nil
+	})
+	return ttl, found, err
+}
+
+func (b *BoltStore) Delete(key string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).Delete([]byte(key))
+	})
+}
+
+func (b *BoltStore) Keys(prefix string) ([]string, error) {
+	var keys []string
+	now := time.Now()
+	err := b.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(bucketName).Cursor()
+		for k, v := cursor.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = cursor.Next() {
+			if !expired(v, now) {
+				keys = append(keys, string(k))
+			}
+		}
+		return nil
+	})
+	return keys, err
+}
+
+func (b *BoltStore) KeyRange(start, end string) ([]string, error) {
+	var keys []string
+	now := time.Now()
+	err := b.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(bucketName).Cursor()
+		for k, v := cursor.Seek([]byte(start)); k != nil && bytes.Compare(k, []byte(end)) < 0; k, v = cursor.Next() {
+			if !expired(v, now) {
+				keys = append(keys, string(k))
+			}
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// Compact deletes every expired entry.
+// It returns the number of deleted entries and an error if any.
+func (b *BoltStore) Compact() (int, error) {
+	removed := 0
+	now := time.Now()
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(bucketName).Cursor()
+		for k, v := cursor.First(); k != nil; {
+			if !expired(v, now) {
+				k, v = cursor.Next()
+				continue
+			}
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+			removed++
+			// Seeking the deleted key lands on the entry right after it.
+			k, v = cursor.Seek(k)
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// Close stops the background compaction and closes the database file.
+func (b *BoltStore) Close() error {
+	close(b.stop)
+	<-b.done
+	return b.db.Close()
+}
+
+func (b *BoltStore) compactLoop(interval time.Duration) {
+	defer close(b.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			removed, err := b.Compact()
+			if err != nil {
+				slog.Error("Store compaction failed", "err", err)
+				continue
+			}
+			if removed > 0 {
+				slog.Info("Store compaction removed expired entries", "removed", removed)
+			}
+		}
+	}
+}
+
+func expired(record []byte, now time.Time) bool {
+	if len(record) < 8 {
+		return true
+	}
+	expiresAt := int64(binary.BigEndian.Uint64(record))
+	return expiresAt != 0 && now.UnixNano() > expiresAt
+}
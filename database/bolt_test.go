@@ -0,0 +1,202 @@
+package database
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func openTestBolt(t *testing.T) *BoltStore {
+	t.Helper()
+	store, err := OpenBoltStore(filepath.Join(t.TempDir(), "state.db"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// putExpired writes a record that expired a minute ago, bypassing Set.
+func putExpired(t *testing.T, store *BoltStore, key string) {
+	t.Helper()
+	record := make([]byte, 8+len(key))
+	binary.BigEndian.PutUint64(record, uint64(time.Now().Add(-time.Minute).UnixNano()))
+	copy(record[8:], key)
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).Put([]byte(key), record)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBoltStoreSetGet(t *testing.T) {
+	store := openTestBolt(t)
+
+	if err := store.Set("session:T1:U1", []byte("value"), 0); err != nil {
+		t.Fatal(err)
+	}
+	value, found, err := store.Get("session:T1:U1")
+	if err != nil || !found || string(value) != "value" {
+		t.Fatalf("Get = %q, %v, %v; want value", value, found, err)
+	}
+
+	if err := store.Set("session:T1:U1", []byte("other"), 0); err != nil {
+		t.Fatal(err)
+	}
+	value, _, _ = store.Get("session:T1:U1")
+	if string(value) != "other" {
+		t.Errorf("Get after overwrite = %q, want other", value)
+	}
+
+	if err := store.Delete("session:T1:U1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := store.Get("session:T1:U1"); found {
+		t.Error("Get found a deleted key")
+	}
+}
+
+func TestBoltStoreExpiry(t *testing.T) {
+	store := openTestBolt(t)
+
+	if err := store.Set("short", []byte("v"), 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("long", []byte("v"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("forever", []byte("v"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	ttl, found, err := store.TTL("long")
+	if err != nil || !found || ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("TTL(long) = %v, %v, %v; want about an hour", ttl, found, err)
+	}
+	ttl, found, _ = store.TTL("forever")
+	if !found || ttl != 0 {
+		t.Errorf("TTL(forever) = %v, %v; want 0 and found", ttl, found)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if _, found, _ := store.Get("short"); found {
+		t.Error("Get returned an expired value")
+	}
+	if _, found, _ := store.TTL("short"); found {
+		t.Error("TTL found an expired value")
+	}
+	if _, found, _ := store.Get("long"); !found {
+		t.Error("Get did not find a value that has not expired")
+	}
+}
+
+func TestBoltStoreKeys(t *testing.T) {
+	store := openTestBolt(t)
+	for _, key := range []string{"session:T1:U1", "session:T1:U2", "session:T2:U1", "approval:1"} {
+		if err := store.Set(key, []byte("v"), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	putExpired(t, store, "session:T1:U3")
+
+	keys, err := store.Keys("session:T1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"session:T1:U1", "session:T1:U2"}; !slices.Equal(keys, want) {
+		t.Errorf("Keys = %v, want %v", keys, want)
+	}
+
+	all, _ := store.Keys("")
+	if len(all) != 4 {
+		t.Errorf("Keys(\"\") = %v, want the 4 live keys", all)
+	}
+}
+
+func TestBoltStoreKeyRange(t *testing.T) {
+	store := openTestBolt(t)
+	for _, key := range []string{"audit:1:a", "audit:2:b", "audit:3:c", "audit:4:d", "session:T1:U1"} {
+		if err := store.Set(key, []byte("v"), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	putExpired(t, store, "audit:2:x")
+
+	keys, err := store.KeyRange("audit:2", "audit:4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"audit:2:b", "audit:3:c"}; !slices.Equal(keys, want) {
+		t.Errorf("KeyRange = %v, want %v", keys, want)
+	}
+}
+
+func TestBoltStoreCompact(t *testing.T) {
+	store := openTestBolt(t)
+
+	// Expired records next to each other, first, last and between live ones.
+	layout := []struct {
+		key     string
+		expired bool
+	}{
+		{"a", true}, {"b", false}, {"c", true}, {"d", true}, {"e", false}, {"f", true}, {"g", false}, {"h", true},
+	}
+	for _, record := range layout {
+		if record.expired {
+			putExpired(t, store, record.key)
+		} else if err := store.Set(record.key, []byte(record.key), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := store.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 5 {
+		t.Errorf("Compact removed %d records, want 5", removed)
+	}
+
+	var remaining []string
+	store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).ForEach(func(k, v []byte) error {
+			remaining = append(remaining, string(k))
+			return nil
+		})
+	})
+	if want := []string{"b", "e", "g"}; !slices.Equal(remaining, want) {
+		t.Errorf("records after Compact = %v, want %v", remaining, want)
+	}
+
+	removed, _ = store.Compact()
+	if removed != 0 {
+		t.Errorf("second Compact removed %d records, want 0", removed)
+	}
+}
+
+func TestBoltStoreSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	store, err := OpenBoltStore(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("key", []byte("value"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	store, err = OpenBoltStore(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	value, found, err := store.Get("key")
+	if err != nil || !found || string(value) != "value" {
+		t.Errorf("Get after reopen = %q, %v, %v; want value", value, found, err)
+	}
+}
@@ -1,12 +1,46 @@
 package database
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/patrickmn/go-cache"
 )
 
+var ErrInvalidStoreType = errors.New("invalid store type")
+
+// Store keeps the state of the bot, such as user sessions and environment ids.
+// Values are opaque bytes and expire after their TTL. A TTL of 0 means the value never expires.
+type Store interface {
+	Get(key string) ([]byte, bool, error)
+	Set(key string, value []byte, ttl time.Duration) error
+	TTL(key string) (time.Duration, bool, error)
+	Delete(key string) error
+	Keys(prefix string) ([]string, error)
+	// KeyRange returns the keys from start up to but not including end, in ascending order.
+	KeyRange(start, end string) ([]string, error)
+	Close() error
+}
+
 func CreateCache() *cache.Cache {
 	c := cache.New(1800*time.Second, 1800*time.Second)
 	return c
 }
+
+// NewStore creates the store selected by storeType.
+// It takes the store type ("memory" or "bolt") and the database file used by the bolt store.
+// It returns the store and an error if any.
+func NewStore(storeType, path string) (Store, error) {
+	switch storeType {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "bolt":
+		if path == "" {
+			path = "slack-bot.db"
+		}
+		return OpenBoltStore(path, 10*time.Minute)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidStoreType, storeType)
+	}
+}
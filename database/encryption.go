@@ -0,0 +1,289 @@
+package database
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoMasterKey      = errors.New("no master key configured")
+	ErrUnknownMasterKey = errors.New("record is encrypted with an unknown master key")
+)
+
+// MasterKey is a 256 bit key used to encrypt the per record data keys.
+// Its ID is derived from the key itself so records can name the key that sealed them.
+type MasterKey struct {
+	ID  string
+	key []byte
+}
+
+// Keyring holds the master key used for new records and the older keys that
+// are still accepted for reading while a rotation is in progress.
+type Keyring struct {
+	primary *MasterKey
+	keys    map[string]*MasterKey
+}
+
+// envelope is what ends up in the underlying store for every encrypted value.
+type envelope struct {
+	KeyID      string `json:"kid"`
+	DataKey    []byte `json:"dek"`
+	Ciphertext []byte `json:"data"`
+}
+
+// NewMasterKey creates a master key from 32 raw bytes.
+func NewMasterKey(key []byte) (*MasterKey, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("master key must be 32 bytes, got %d", len(key))
+	}
+	sum := sha256.Sum256(key)
+	return &MasterKey{ID: hex.EncodeToString(sum[:8]), key: key}, nil
+}
+
+// NewKeyring creates a keyring whose first key is the primary key.
+func NewKeyring(keys ...*MasterKey) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoMasterKey
+	}
+	keyring := &Keyring{primary: keys[0], keys: map[string]*MasterKey{}}
+	for _, key := range keys {
+		keyring.keys[key.ID] = key
+	}
+	return keyring, nil
+}
+
+// LoadKeyring reads the master keys from a key file or, if no file is given, from an env var value.
+// Keys are base64 encoded, one per line. The first key is the primary key, the others are
+// previous keys kept around until the records have been rotated.
+// It returns ErrNoMasterKey when neither source is set.
+func LoadKeyring(keyFile, keyValue string) (*Keyring, error) {
+	var source io.Reader
+	switch {
+	case keyFile != "":
+		file, err := os.Open(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		source = file
+	case keyValue != "":
+		source = strings.NewReader(strings.ReplaceAll(keyValue, ",", "\n"))
+	default:
+		return nil, ErrNoMasterKey
+	}
+
+	var keys []*MasterKey
+	scanner := bufio.NewScanner(source)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid master key: %w", err)
+		}
+		key, err := NewMasterKey(raw)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewKeyring(keys...)
+}
+
+// EphemeralKeyring creates a keyring with a random key that only lives as long as the process.
+func EphemeralKeyring() (*Keyring, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	key, err := NewMasterKey(raw)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyring(key)
+}
+
+// EncryptedStore encrypts every value before handing it to the wrapped store.
+// Each value gets its own random data key, which is in turn sealed with the primary master key.
+type EncryptedStore struct {
+	Store
+	keyring *Keyring
+}
+
+func NewEncryptedStore(store Store, keyring *Keyring) *EncryptedStore {
+	return &EncryptedStore{Store: store, keyring: keyring}
+}
+
+func (e *EncryptedStore) Get(key string) ([]byte, bool, error) {
+	record, found, err := e.Store.Get(key)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	value, err := e.open(key, record)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (e *EncryptedStore) Set(key string, value []byte, ttl time.Duration) error {
+	record, err := e.seal(key, value)
+	if err != nil {
+		return err
+	}
+	return e.Store.Set(key, record, ttl)
+}
+
+// Verify checks that every record in the store was sealed with a key of the keyring.
+// It is meant to run at startup so a wrong key file is noticed before any request is served.
+func (e *EncryptedStore) Verify() error {
+	keys, err := e.Store.Keys("")
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		record, found, err := e.Store.Get(key)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+		if _, err := e.open(key, record); err != nil {
+			return fmt.Errorf("record %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// Rotate re-encrypts every record that is not sealed with the primary key.
+// It returns the number of re-encrypted records and an error if any.
+func (e *EncryptedStore) Rotate() (int, error) {
+	keys, err := e.Store.Keys("")
+	if err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+	for _, key := range keys {
+		record, found, err := e.Store.Get(key)
+		if err != nil {
+			return rotated, err
+		}
+		if !found {
+			continue
+		}
+
+		env := envelope{}
+		if err := json.Unmarshal(record, &env); err != nil {
+			return rotated, fmt.Errorf("record %q: %w", key, err)
+		}
+		if env.KeyID == e.keyring.primary.ID {
+			continue
+		}
+
+		ttl, found, err := e.Store.TTL(key)
+		if err != nil {
+			return rotated, err
+		}
+		if !found {
+			continue
+		}
+		value, err := e.open(key, record)
+		if err != nil {
+			return rotated, fmt.Errorf("record %q: %w", key, err)
+		}
+		if err := e.Set(key, value, ttl); err != nil {
+			return rotated, err
+		}
+		rotated++
+	}
+	return rotated, nil
+}
+
+func (e *EncryptedStore) seal(key string, value []byte) ([]byte, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := encrypt(dataKey, value, []byte(key))
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, err := encrypt(e.keyring.primary.key, dataKey, []byte(e.keyring.primary.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(envelope{KeyID: e.keyring.primary.ID, DataKey: wrappedKey, Ciphertext: ciphertext})
+}
+
+func (e *EncryptedStore) open(key string, record []byte) ([]byte, error) {
+	env := envelope{}
+	if err := json.Unmarshal(record, &env); err != nil {
+		return nil, fmt.Errorf("record is not encrypted: %w", err)
+	}
+
+	masterKey, found := e.keyring.keys[env.KeyID]
+	if !found {
+		return nil, fmt.Errorf("%w (%s)", ErrUnknownMasterKey, env.KeyID)
+	}
+
+	dataKey, err := decrypt(masterKey.key, env.DataKey, []byte(env.KeyID))
+	if err != nil {
+		return nil, err
+	}
+	return decrypt(dataKey, env.Ciphertext, []byte(key))
+}
+
+// encrypt seals plaintext with AES-256-GCM and prepends the nonce.
+// The additional data binds the ciphertext to its store key or master key id.
+func encrypt(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func decrypt(key, ciphertext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func testKey(t *testing.T, fill byte) *MasterKey {
+	t.Helper()
+	key, err := NewMasterKey(bytes.Repeat([]byte{fill}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func testStore(t *testing.T, store Store, keys ...*MasterKey) *EncryptedStore {
+	t.Helper()
+	keyring, err := NewKeyring(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewEncryptedStore(store, keyring)
+}
+
+func keyID(t *testing.T, store Store, key string) string {
+	t.Helper()
+	record, found, err := store.Get(key)
+	if err != nil || !found {
+		t.Fatalf("record %q: found=%v err=%v", key, found, err)
+	}
+	env := envelope{}
+	if err := json.Unmarshal(record, &env); err != nil {
+		t.Fatal(err)
+	}
+	return env.KeyID
+}
+
+func TestEncryptedStoreRoundTrip(t *testing.T) {
+	raw := NewMemoryStore()
+	store := testStore(t, raw, testKey(t, 1))
+
+	if err := store.Set("session:T1:U1", []byte("secret token"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	record, _, _ := raw.Get("session:T1:U1")
+	if bytes.Contains(record, []byte("secret token")) {
+		t.Fatalf("record is stored in plain text: %s", record)
+	}
+
+	value, found, err := store.Get("session:T1:U1")
+	if err != nil || !found {
+		t.Fatalf("Get: found=%v err=%v", found, err)
+	}
+	if string(value) != "secret token" {
+		t.Fatalf("Get = %q, want %q", value, "secret token")
+	}
+
+	_, found, err = store.Get("session:T1:U2")
+	if err != nil || found {
+		t.Fatalf("Get of missing key: found=%v err=%v", found, err)
+	}
+}
+
+func TestEncryptedStoreBindsRecordToKey(t *testing.T) {
+	raw := NewMemoryStore()
+	store := testStore(t, raw, testKey(t, 1))
+
+	if err := store.Set("session:T1:U1", []byte("secret token"), 0); err != nil {
+		t.Fatal(err)
+	}
+	record, _, _ := raw.Get("session:T1:U1")
+	raw.Set("session:T1:U2", record, 0)
+
+	_, found, err := store.Get("session:T1:U2")
+	if err == nil || found {
+		t.Fatalf("Get of copied record: found=%v err=%v, want an error", found, err)
+	}
+}
+
+func TestEncryptedStoreVerify(t *testing.T) {
+	raw := NewMemoryStore()
+	oldKey, newKey := testKey(t, 1), testKey(t, 2)
+
+	if err := testStore(t, raw, oldKey).Set("state:a", []byte("a"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := testStore(t, raw, newKey, oldKey).Verify(); err != nil {
+		t.Fatalf("Verify with the old key in the keyring: %v", err)
+	}
+	err := testStore(t, raw, newKey).Verify()
+	if !errors.Is(err, ErrUnknownMasterKey) {
+		t.Fatalf("Verify without the old key = %v, want %v", err, ErrUnknownMasterKey)
+	}
+}
+
+func TestEncryptedStoreRotate(t *testing.T) {
+	raw := NewMemoryStore()
+	oldKey, newKey := testKey(t, 1), testKey(t, 2)
+
+	old := testStore(t, raw, oldKey)
+	if err := old.Set("state:expiring", []byte("expiring"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := old.Set("state:forever", []byte("forever"), 0); err != nil {
+		t.Fatal(err)
+	}
+	store := testStore(t, raw, newKey, oldKey)
+	if err := store.Set("state:current", []byte("current"), 0); err != nil {
+		t.Fatal(err)
+	}
+	current, _, _ := raw.Get("state:current")
+
+	rotated, err := store.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated != 2 {
+		t.Fatalf("Rotate re-encrypted %d records, want 2", rotated)
+	}
+
+	after, _, _ := raw.Get("state:current")
+	if !bytes.Equal(current, after) {
+		t.Error("Rotate re-encrypted a record sealed with the primary key")
+	}
+	for key, want := range map[string]string{"state:expiring": "expiring", "state:forever": "forever", "state:current": "current"} {
+		if id := keyID(t, raw, key); id != newKey.ID {
+			t.Errorf("%s is sealed with %s, want %s", key, id, newKey.ID)
+		}
+		value, _, err := testStore(t, raw, newKey).Get(key)
+		if err != nil || string(value) != want {
+			t.Errorf("%s = %q, %v; want %q", key, value, err, want)
+		}
+	}
+
+	ttl, found, _ := raw.TTL("state:expiring")
+	if !found || ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("TTL of state:expiring = %v, want about an hour", ttl)
+	}
+	ttl, found, _ = raw.TTL("state:forever")
+	if !found || ttl != 0 {
+		t.Errorf("TTL of state:forever = %v, want no expiry", ttl)
+	}
+}
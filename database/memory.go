@@ -0,0 +1,73 @@
+package database
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+// MemoryStore is a Store that lives in process memory and is lost on restart.
+// Expired entries are removed by the go-cache janitor.
+type MemoryStore struct {
+	cache *cache.Cache
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{cache: CreateCache()}
+}
+
+func (m *MemoryStore) Get(key string) ([]byte, bool, error) {
+	value, found := m.cache.Get(key)
+	if !found {
+		return nil, false, nil
+	}
+	return value.([]byte), true, nil
+}
+
+func (m *MemoryStore) Set(key string, value []byte, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = cache.NoExpiration
+	}
+	m.cache.Set(key, value, ttl)
+	return nil
+}
+
+func (m *MemoryStore) TTL(key string) (time.Duration, bool, error) {
+	_, expiresAt, found := m.cache.GetWithExpiration(key)
+	if !found || expiresAt.IsZero() {
+		return 0, found, nil
+	}
+	return time.Until(expiresAt), true, nil
+}
+
+func (m *MemoryStore) Delete(key string) error {
+	m.cache.Delete(key)
+	return nil
+}
+
+func (m *MemoryStore) Keys(prefix string) ([]string, error) {
+	var keys []string
+	for key := range m.cache.Items() {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (m *MemoryStore) KeyRange(start, end string) ([]string, error) {
+	var keys []string
+	for key := range m.cache.Items() {
+		if key >= start && key < end {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,326 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/jchawla2804/golang-slack-event-listener/events"
+	"github.com/jchawla2804/golang-slack-event-listener/logging"
+	"github.com/jchawla2804/golang-slack-event-listener/workspace"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// ErrShuttingDown is returned for events that arrive after Shutdown was called.
+var ErrShuttingDown = errors.New("the bot is shutting down, please try again in a minute")
+
+// ErrBusy is returned for events of a user who already has too many events waiting,
+// or when too many events of all users are waiting.
+var ErrBusy = errors.New("the bot is busy right now, please try again in a moment")
+
+// ErrUnknownWorkspace is returned for events of a Slack team the bot is not configured for.
+var ErrUnknownWorkspace = errors.New("the bot is not configured for this workspace")
+
+// Dispatcher routes Slack events to the handlers in events.
+// Every event runs in isolation: an error or a panic in one handler is reported
+// to the user who triggered it and never stops the listener.
+// Every user has a queue of their own, so a user's commands run one at a time in the order
+// they were sent. A pool of workers takes the next event of any user who has none running,
+// so a slow command only holds up the user who sent it.
+// Every event is answered with the Slack client of the workspace it came from.
+type Dispatcher struct {
+	workspaces *workspace.Registry
+
+	workers        int
+	queueSize      int
+	maxPending     int
+	handlerTimeout time.Duration
+
+	mu     sync.Mutex
+	closed bool
+	// queues holds the events of every user with events waiting or running, by team and user id.
+	queues map[string]*queue
+	// ready lists, in arrival order, the users with events waiting and none running.
+	ready []string
+	// pending counts the events of all users that wait for a worker.
+	pending int
+	// wake is signalled when a user becomes ready or the dispatcher is shut down.
+	wake     *sync.Cond
+	inflight sync.WaitGroup
+	// abort is cancelled when handlers did not finish before the shutdown deadline.
+	abort       context.Context
+	cancelAbort context.CancelFunc
+}
+
+// queue is the events of one user.
+type queue struct {
+	jobs    []job
+	running bool
+}
+
+// job is an event waiting for a worker.
+type job struct {
+	ctx       context.Context
+	name      string
+	rc        events.ResponseContext
+	workspace *workspace.Workspace
+	handler   func(ctx context.Context, slackClient *slack.Client) error
+}
+
+// New creates a dispatcher for the events of the given workspaces and starts its workers.
+func New(workspaces *workspace.Registry, options ...Option) *Dispatcher {
+	abort, cancelAbort := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		workspaces:     workspaces,
+		workers:        8,
+		queueSize:      16,
+		maxPending:     256,
+		handlerTimeout: 5 * time.Minute,
+		queues:         map[string]*queue{},
+		abort:          abort,
+		cancelAbort:    cancelAbort,
+	}
+	d.wake = sync.NewCond(&d.mu)
+	for _, option := range options {
+		option(d)
+	}
+
+	for i := 0; i < d.workers; i++ {
+		go d.work()
+	}
+	return d
+}
+
+// Shutdown stops accepting events and waits for the handlers that are running or queued.
+// Handlers get until ctx is done; after that their contexts are cancelled and ctx.Err() is returned.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	d.closed = true
+	d.wake.Broadcast()
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		d.cancelAbort()
+		return nil
+	case <-ctx.Done():
+		d.cancelAbort()
+		return ctx.Err()
+	}
+}
+
+// enqueue adds a job to the queue of its user without waiting.
+// It returns ErrShuttingDown or ErrBusy when the job cannot be queued.
+func (d *Dispatcher) enqueue(j job) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return ErrShuttingDown
+	}
+
+	if d.pending >= d.maxPending {
+		return ErrBusy
+	}
+
+	key := j.rc.TeamID + ":" + j.rc.UserID
+	q, found := d.queues[key]
+	if !found {
+		q = &queue{}
+		d.queues[key] = q
+	}
+	// The next event of an idle user is not waiting behind another one, only for a free worker.
+	if (q.running && len(q.jobs) >= d.queueSize) || (!q.running && len(q.jobs) > d.queueSize) {
+		return ErrBusy
+	}
+
+	d.inflight.Add(1)
+	d.pending++
+	q.jobs = append(q.jobs, j)
+	if !q.running && len(q.jobs) == 1 {
+		d.ready = append(d.ready, key)
+		d.wake.Signal()
+	}
+	return nil
+}
+
+// work runs the next event of a ready user until the dispatcher is shut down and no events are left.
+func (d *Dispatcher) work() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for {
+		for len(d.ready) == 0 && !d.closed {
+			d.wake.Wait()
+		}
+		if len(d.ready) == 0 {
+			// Users with events left are ready again once their running event is done,
+			// and the worker running it picks them up.
+			return
+		}
+
+		key := d.ready[0]
+		d.ready = d.ready[1:]
+		q := d.queues[key]
+		j := q.jobs[0]
+		q.jobs = q.jobs[1:]
+		q.running = true
+		d.pending--
+
+		d.mu.Unlock()
+		d.execute(j)
+		d.inflight.Done()
+		d.mu.Lock()
+
+		q.running = false
+		if len(q.jobs) > 0 {
+			d.ready = append(d.ready, key)
+			d.wake.Signal()
+		} else {
+			delete(d.queues, key)
+		}
+	}
+}
+
+// HandleEventsAPI handles an Events API event such as an app mention.
+func (d *Dispatcher) HandleEventsAPI(ctx context.Context, event slackevents.EventsAPIEvent) {
+	rc := events.ResponseContext{TeamID: event.TeamID}
+	if mention, ok := event.InnerEvent.Data.(*slackevents.AppMentionEvent); ok {
+		rc = events.MentionResponse(event.TeamID, mention)
+	}
+
+	d.run(ctx, "events api "+event.InnerEvent.Type, rc, func(ctx context.Context, slackClient *slack.Client) error {
+		return events.HandleSlackEventMessage(ctx, event, slackClient)
+	})
+}
+
+// HandleSlashCommand handles a slash command.
+func (d *Dispatcher) HandleSlashCommand(ctx context.Context, command slack.SlashCommand) {
+	d.run(ctx, "command "+command.Command, events.CommandResponse(command), func(ctx context.Context, slackClient *slack.Client) error {
+		return events.HandleSlackCommands(ctx, slackClient, command)
+	})
+}
+
+// HandleInteraction handles button clicks, menu selections and modal submissions.
+func (d *Dispatcher) HandleInteraction(ctx context.Context, callback slack.InteractionCallback) {
+	rc := events.InteractionResponse(callback)
+
+	d.run(ctx, "interaction "+string(callback.Type), rc, func(ctx context.Context, slackClient *slack.Client) error {
+		switch callback.Type {
+
+		// case for block actions
+		case slack.InteractionTypeBlockActions:
+			if len(callback.ActionCallback.BlockActions) == 0 {
+				return nil
+			}
+			action := callback.ActionCallback.BlockActions[0]
+
+			switch action.ActionID {
+			case events.ApproveActionID:
+				return events.HandleApprovalDecision(ctx, slackClient, rc, action.Value, true)
+			case events.RejectActionID:
+				return events.HandleApprovalDecision(ctx, slackClient, rc, action.Value, false)
+			case events.ConfirmStatusChangeActionID:
+				return events.HandleStatusChangeButton(ctx, slackClient, rc, action.Value)
+			}
+
+			switch action.Type {
+			case slack.ActionType(slack.OptTypeStatic):
+				return events.HandlePlatformInformation(ctx, slackClient, rc, action.BlockID, action.SelectedOption.Value)
+
+			default:
+				return events.HandleInteractiveDialogBoxEvent(ctx, slackClient, rc, action.Value, callback.TriggerID)
+			}
+
+		// case Submission events
+		case slack.InteractionTypeViewSubmission:
+			if callback.View.CallbackID == events.ConfirmStatusChangeCallbackID {
+				return events.HandleStatusChangeConfirmation(ctx, slackClient, callback.View.PrivateMetadata, callback.User.ID)
+			}
+
+			var username, password, typeOfAuth, controlPlane string
+			values := callback.View.State.Values
+
+			if values["username"]["user"].Value == "" {
+				username = values["clientId"]["clientId"].Value
+				password = values["clientSecret"]["clientSecret"].Value
+				typeOfAuth = "oauth"
+			} else {
+				username = values["username"]["user"].Value
+				password = values["password"]["pass"].Value
+				typeOfAuth = "basic-auth"
+			}
+
+			if selected := values["controlPlane"]["plane"].SelectedOption; selected.Value != "" {
+				controlPlane = selected.Value
+			}
+
+			return events.HandleLogin(ctx, slackClient, rc, username, password, typeOfAuth, controlPlane)
+		}
+		return nil
+	})
+}
+
+// run queues handler for a worker, or reports to the user why it cannot be queued.
+// The user, team and workspace of the request are added to every record logged with the handler's context.
+func (d *Dispatcher) run(ctx context.Context, name string, rc events.ResponseContext, handler func(ctx context.Context, slackClient *slack.Client) error) {
+	ctx = logging.With(ctx, "user", rc.UserID, "team", rc.TeamID)
+	ws, found := d.workspaces.Lookup(rc.TeamID)
+	if !found {
+		slog.WarnContext(ctx, "Dropped event of unknown workspace", "event", name, "err", ErrUnknownWorkspace)
+		return
+	}
+	ctx = logging.With(ctx, "workspace", ws.Name)
+
+	j := job{ctx: ctx, name: name, rc: rc, workspace: ws, handler: handler}
+	err := d.enqueue(j)
+	if err != nil {
+		d.report(ctx, j, err)
+	}
+}
+
+// execute calls the handler of a job, turning a panic into an error, and reports any error to the user.
+// A handler keeps running when the event's context is cancelled, so a shutdown does not interrupt it
+// halfway; its context is only cancelled after the handler timeout or when the shutdown deadline has passed.
+func (d *Dispatcher) execute(j job) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(j.ctx), d.handlerTimeout)
+	defer cancel()
+	stop := context.AfterFunc(d.abort, cancel)
+	defer stop()
+	name, handler := j.name, j.handler
+
+	err := func() (err error) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				slog.ErrorContext(ctx, "Panic while handling "+name, "panic", recovered, "stack", string(debug.Stack()))
+				err = fmt.Errorf("something went wrong while handling your request")
+			}
+		}()
+		return handler(ctx, j.workspace.Client)
+	}()
+	if err == nil {
+		return
+	}
+	d.report(ctx, j, err)
+}
+
+// report logs the error of a job's handler and tells the user who triggered it.
+func (d *Dispatcher) report(ctx context.Context, j job, err error) {
+	slog.ErrorContext(ctx, "Error while handling "+j.name, "err", err)
+	if j.rc.UserID == "" {
+		return
+	}
+	err = events.ReportError(j.workspace.Client, j.rc, err)
+	if err != nil {
+		slog.ErrorContext(ctx, "Could not report error to user", "err", err)
+	}
+}
@@ -0,0 +1,253 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jchawla2804/golang-slack-event-listener/config"
+	"github.com/jchawla2804/golang-slack-event-listener/events"
+	"github.com/jchawla2804/golang-slack-event-listener/workspace"
+	"github.com/slack-go/slack"
+)
+
+// testDispatcher creates a dispatcher for team T1 whose Slack client talks to a local server.
+func testDispatcher(t *testing.T, options ...Option) *Dispatcher {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(server.Close)
+
+	ws, err := workspace.Connect(context.Background(), config.Workspace{Name: "test", TeamID: "T1", BotToken: "xoxb-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.Client = slack.New("xoxb-test", slack.OptionAPIURL(server.URL+"/"))
+	workspaces, err := workspace.NewRegistry(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := New(workspaces, options...)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		d.Shutdown(ctx)
+	})
+	return d
+}
+
+// testJob is a job of a user in team T1 that runs handler.
+func testJob(d *Dispatcher, user string, handler func(ctx context.Context) error) job {
+	ws, _ := d.workspaces.Lookup("T1")
+	return job{
+		ctx:       context.Background(),
+		name:      "test",
+		rc:        events.ResponseContext{TeamID: "T1", UserID: user},
+		workspace: ws,
+		handler: func(ctx context.Context, slackClient *slack.Client) error {
+			return handler(ctx)
+		},
+	}
+}
+
+// blockingJob is a job that runs until release is closed. started is closed once it runs.
+func blockingJob(d *Dispatcher, user string) (j job, started, release chan struct{}) {
+	started, release = make(chan struct{}), make(chan struct{})
+	j = testJob(d, user, func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+	return j, started, release
+}
+
+func waitFor(t *testing.T, ch chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+func TestEventsOfAUserRunInOrder(t *testing.T) {
+	d := testDispatcher(t, OptionWorkers(4))
+
+	var mu sync.Mutex
+	var order []int
+	running := 0
+	done := make(chan struct{})
+	const count = 10
+	for i := 0; i < count; i++ {
+		err := d.enqueue(testJob(d, "U1", func(ctx context.Context) error {
+			mu.Lock()
+			running++
+			if running > 1 {
+				t.Errorf("%d events of the same user ran at once", running)
+			}
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+			running--
+			order = append(order, i)
+			if len(order) == count {
+				close(done)
+			}
+			return nil
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	waitFor(t, done, "the events of U1")
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.IsSorted(order) {
+		t.Errorf("events ran in order %v, want the order they were sent in", order)
+	}
+}
+
+func TestSlowUserDoesNotHoldUpOthers(t *testing.T) {
+	d := testDispatcher(t, OptionWorkers(2))
+
+	slow, started, release := blockingJob(d, "U1")
+	defer close(release)
+	if err := d.enqueue(slow); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, started, "the slow event")
+
+	// The next event of U1 waits behind the slow one, the event of U2 does not.
+	ranForU1 := make(chan struct{})
+	if err := d.enqueue(testJob(d, "U1", func(ctx context.Context) error { close(ranForU1); return nil })); err != nil {
+		t.Fatal(err)
+	}
+	ranForU2 := make(chan struct{})
+	if err := d.enqueue(testJob(d, "U2", func(ctx context.Context) error { close(ranForU2); return nil })); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, ranForU2, "the event of U2")
+	select {
+	case <-ranForU1:
+		t.Error("the second event of U1 ran while the first was still running")
+	default:
+	}
+}
+
+func TestBusy(t *testing.T) {
+	noop := func(ctx context.Context) error { return nil }
+
+	t.Run("per user", func(t *testing.T) {
+		d := testDispatcher(t, OptionWorkers(1), OptionQueueSize(2))
+		slow, started, release := blockingJob(d, "U1")
+		defer close(release)
+		d.enqueue(slow)
+		waitFor(t, started, "the slow event")
+
+		for i := 0; i < 2; i++ {
+			if err := d.enqueue(testJob(d, "U1", noop)); err != nil {
+				t.Fatalf("event %d behind the running one: %v", i+1, err)
+			}
+		}
+		if err := d.enqueue(testJob(d, "U1", noop)); !errors.Is(err, ErrBusy) {
+			t.Errorf("event beyond the queue size: %v, want %v", err, ErrBusy)
+		}
+		if err := d.enqueue(testJob(d, "U2", noop)); err != nil {
+			t.Errorf("event of another user: %v", err)
+		}
+	})
+
+	t.Run("all users", func(t *testing.T) {
+		d := testDispatcher(t, OptionWorkers(1), OptionMaxPending(3))
+		slow, started, release := blockingJob(d, "U0")
+		defer close(release)
+		d.enqueue(slow)
+		waitFor(t, started, "the slow event")
+
+		for _, user := range []string{"U1", "U2", "U3"} {
+			if err := d.enqueue(testJob(d, user, noop)); err != nil {
+				t.Fatalf("event of %s: %v", user, err)
+			}
+		}
+		if err := d.enqueue(testJob(d, "U4", noop)); !errors.Is(err, ErrBusy) {
+			t.Errorf("event beyond the pending cap: %v, want %v", err, ErrBusy)
+		}
+	})
+}
+
+func TestShutdownDrainsQueuedEvents(t *testing.T) {
+	d := testDispatcher(t, OptionWorkers(1))
+
+	slow, started, release := blockingJob(d, "U1")
+	d.enqueue(slow)
+	waitFor(t, started, "the slow event")
+
+	var mu sync.Mutex
+	var ran []string
+	for _, user := range []string{"U1", "U2", "U3"} {
+		err := d.enqueue(testJob(d, user, func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			ran = append(ran, user)
+			return nil
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	shutdown := make(chan error)
+	go func() { shutdown <- d.Shutdown(context.Background()) }()
+	time.Sleep(10 * time.Millisecond)
+	if err := d.enqueue(testJob(d, "U4", func(ctx context.Context) error { return nil })); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("event after Shutdown: %v, want %v", err, ErrShuttingDown)
+	}
+	close(release)
+
+	select {
+	case err := <-shutdown:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown did not return")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(ran) != 3 {
+		t.Errorf("events run before Shutdown returned: %v, want all 3", ran)
+	}
+}
+
+func TestShutdownDeadlineCancelsHandlers(t *testing.T) {
+	d := testDispatcher(t, OptionWorkers(1))
+
+	started, cancelled := make(chan struct{}), make(chan struct{})
+	d.enqueue(testJob(d, "U1", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return ctx.Err()
+	}))
+	waitFor(t, started, "the handler")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := d.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown = %v, want %v", err, context.DeadlineExceeded)
+	}
+	waitFor(t, cancelled, "the handler to be cancelled")
+}
@@ -0,0 +1,44 @@
+package dispatcher
+
+import "time"
+
+// Option configures a Dispatcher.
+type Option func(*Dispatcher)
+
+// OptionWorkers sets how many events are handled at the same time.
+func OptionWorkers(workers int) Option {
+	return func(d *Dispatcher) {
+		if workers > 0 {
+			d.workers = workers
+		}
+	}
+}
+
+// OptionQueueSize sets how many events of one user may wait while another of theirs is handled
+// before the user is told to try again.
+func OptionQueueSize(size int) Option {
+	return func(d *Dispatcher) {
+		if size >= 0 {
+			d.queueSize = size
+		}
+	}
+}
+
+// OptionMaxPending sets how many events of all users may wait for a worker
+// before further events are turned away.
+func OptionMaxPending(max int) Option {
+	return func(d *Dispatcher) {
+		if max > 0 {
+			d.maxPending = max
+		}
+	}
+}
+
+// OptionHandlerTimeout sets how long a handler may run before its context is cancelled.
+func OptionHandlerTimeout(timeout time.Duration) Option {
+	return func(d *Dispatcher) {
+		if timeout > 0 {
+			d.handlerTimeout = timeout
+		}
+	}
+}
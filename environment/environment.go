@@ -0,0 +1,196 @@
+package environment
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AnyBusinessGroup is the business group key whose aliases apply to every business group.
+const AnyBusinessGroup = "*"
+
+// Environment is an Anypoint environment a command acts on.
+type Environment struct {
+	Name       string
+	ID         string
+	Production bool
+}
+
+// Aliases maps short names such as dev or prod to environment names, per business group.
+// Business groups are keyed by id or name; aliases under "*" apply to all of them. For example:
+//
+//	aliases:
+//	  "*":
+//	    dev: SO2C-dev
+//	    prod: SO2C-prod
+//	  Payments:
+//	    prod: PAY-prod
+type Aliases map[string]map[string]string
+
+// For returns the aliases of a business group, keyed by lower case alias.
+// Aliases of the business group itself win over those under "*".
+func (a Aliases) For(groupId, groupName string) map[string]string {
+	merged := map[string]string{}
+	for _, key := range []string{AnyBusinessGroup, groupName, groupId} {
+		if key == "" {
+			continue
+		}
+		for alias, name := range a[key] {
+			merged[strings.ToLower(alias)] = name
+		}
+	}
+	return merged
+}
+
+// NotFoundError is returned when a name matches no environment or alias.
+type NotFoundError struct {
+	Name string
+	// Suggestion is the closest known environment or alias, if any is close.
+	Suggestion string
+	Known      []string
+}
+
+func (e *NotFoundError) Error() string {
+	msg := fmt.Sprintf("Unknown environment %q.", e.Name)
+	if e.Suggestion != "" {
+		msg += fmt.Sprintf(" Did you mean %q?", e.Suggestion)
+	}
+	if len(e.Known) > 0 {
+		msg += " Known environments: " + strings.Join(e.Known, ", ")
+	}
+	return msg
+}
+
+// AmbiguousError is returned when a prefix matches more than one environment.
+type AmbiguousError struct {
+	Name    string
+	Matches []string
+}
+
+func (e *AmbiguousError) Error() string {
+	return fmt.Sprintf("Environment %q is ambiguous, it could be %s.", e.Name, strings.Join(e.Matches, ", "))
+}
+
+// ProductionPrefixError is returned when a prefix matches a production environment, which
+// must be named in full or by an alias so a typo cannot change production.
+type ProductionPrefixError struct {
+	Name  string
+	Match string
+}
+
+func (e *ProductionPrefixError) Error() string {
+	return fmt.Sprintf("Environment %q would match production environment %q. Please type its full name or alias.", e.Name, e.Match)
+}
+
+// Resolve finds the environment name meant by name among the names of a business group.
+// name may be an alias, the environment name in any case, or an unambiguous prefix of either.
+// Environments in production are only matched by their full name or an alias.
+func Resolve(name string, names []string, aliases map[string]string, production map[string]bool) (string, error) {
+	names = sorted(names)
+
+	target := name
+	if aliased, found := aliases[strings.ToLower(name)]; found {
+		target = aliased
+	}
+	if match, found := lookup(target, names); found {
+		return match, nil
+	}
+
+	matches := map[string]bool{}
+	prefix := strings.ToLower(name)
+	for _, candidate := range names {
+		if strings.HasPrefix(strings.ToLower(candidate), prefix) {
+			matches[candidate] = true
+		}
+	}
+	for alias, aliased := range aliases {
+		if strings.HasPrefix(alias, prefix) {
+			if match, found := lookup(aliased, names); found {
+				matches[match] = true
+			}
+		}
+	}
+	switch len(matches) {
+	case 1:
+		for match := range matches {
+			if production[match] {
+				return "", &ProductionPrefixError{Name: name, Match: match}
+			}
+			return match, nil
+		}
+	case 0:
+	default:
+		return "", &AmbiguousError{Name: name, Matches: sorted(keys(matches))}
+	}
+
+	known := append([]string{}, names...)
+	for alias := range aliases {
+		known = append(known, alias)
+	}
+	return "", &NotFoundError{Name: name, Suggestion: closest(name, known), Known: names}
+}
+
+// lookup returns the name that equals target, preferring an exact match over one in another case.
+func lookup(target string, names []string) (string, bool) {
+	for _, name := range names {
+		if name == target {
+			return name, true
+		}
+	}
+	for _, name := range names {
+		if strings.EqualFold(name, target) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// closest returns the candidate with the smallest edit distance to name, if it is
+// close enough to be a typo.
+func closest(name string, candidates []string) string {
+	name = strings.ToLower(name)
+	best, bestDistance := "", len(name)/3+2
+	for _, candidate := range sorted(candidates) {
+		distance := editDistance(name, strings.ToLower(candidate))
+		if distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func sorted(values []string) []string {
+	values = append([]string{}, values...)
+	sort.Strings(values)
+	return values
+}
+
+func keys(set map[string]bool) []string {
+	var list []string
+	for key := range set {
+		list = append(list, key)
+	}
+	return list
+}
@@ -0,0 +1,122 @@
+package environment
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	names := []string{"Sandbox", "SO2C-dev", "SO2C-test", "Production", "production-eu"}
+	aliases := map[string]string{"dev": "SO2C-dev", "prod": "Production", "live": "production-eu", "qa": "Missing"}
+	production := map[string]bool{"Production": true, "production-eu": true}
+
+	tests := []struct {
+		name             string
+		input            string
+		want             string
+		notFound         bool
+		ambiguous        []string
+		productionPrefix string
+	}{
+		{name: "exact name", input: "Sandbox", want: "Sandbox"},
+		{name: "name in another case", input: "so2c-test", want: "SO2C-test"},
+		{name: "alias", input: "dev", want: "SO2C-dev"},
+		{name: "alias in another case", input: "DEV", want: "SO2C-dev"},
+		{name: "unique prefix", input: "sand", want: "Sandbox"},
+		{name: "prefix of an alias", input: "de", want: "SO2C-dev"},
+		{name: "ambiguous prefix", input: "so2c", ambiguous: []string{"SO2C-dev", "SO2C-test"}},
+		{name: "production by full name", input: "production", want: "Production"},
+		{name: "production by alias", input: "prod", want: "Production"},
+		{name: "production by prefix", input: "production-", productionPrefix: "production-eu"},
+		{name: "production by prefix of an alias", input: "liv", productionPrefix: "production-eu"},
+		{name: "prefix of several production environments", input: "pro", ambiguous: []string{"Production", "production-eu"}},
+		{name: "alias of a missing environment", input: "qa", notFound: true},
+		{name: "unknown", input: "staging", notFound: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Resolve(tc.input, names, aliases, production)
+
+			var notFound *NotFoundError
+			var ambiguous *AmbiguousError
+			var prefix *ProductionPrefixError
+			switch {
+			case tc.notFound:
+				if !errors.As(err, &notFound) {
+					t.Errorf("Resolve(%q) = %q, %v; want a NotFoundError", tc.input, got, err)
+				}
+			case tc.ambiguous != nil:
+				if !errors.As(err, &ambiguous) || !reflect.DeepEqual(ambiguous.Matches, tc.ambiguous) {
+					t.Errorf("Resolve(%q) = %q, %v; want ambiguous between %v", tc.input, got, err, tc.ambiguous)
+				}
+			case tc.productionPrefix != "":
+				if !errors.As(err, &prefix) || prefix.Match != tc.productionPrefix {
+					t.Errorf("Resolve(%q) = %q, %v; want a ProductionPrefixError for %s", tc.input, got, err, tc.productionPrefix)
+				}
+			default:
+				if err != nil || got != tc.want {
+					t.Errorf("Resolve(%q) = %q, %v; want %q", tc.input, got, err, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveSuggestsClosestName(t *testing.T) {
+	_, err := Resolve("sandbx", []string{"Sandbox", "Production"}, map[string]string{"prod": "Production"}, nil)
+	var notFound *NotFoundError
+	if !errors.As(err, &notFound) {
+		t.Fatalf("Resolve = %v, want a NotFoundError", err)
+	}
+	if notFound.Suggestion != "Sandbox" {
+		t.Errorf("Suggestion = %q, want Sandbox", notFound.Suggestion)
+	}
+	if !reflect.DeepEqual(notFound.Known, []string{"Production", "Sandbox"}) {
+		t.Errorf("Known = %v, want the environment names", notFound.Known)
+	}
+}
+
+func TestAliasesFor(t *testing.T) {
+	aliases := Aliases{
+		AnyBusinessGroup: {"dev": "SO2C-dev", "Prod": "SO2C-prod"},
+		"Payments":       {"prod": "PAY-prod", "uat": "PAY-uat"},
+		"bg-123":         {"uat": "PAY-uat-2"},
+	}
+	tests := []struct {
+		name               string
+		groupId, groupName string
+		want               map[string]string
+	}{
+		{
+			name:    "other business group",
+			groupId: "bg-999", groupName: "Sales",
+			want: map[string]string{"dev": "SO2C-dev", "prod": "SO2C-prod"},
+		},
+		{
+			name:    "by name",
+			groupId: "bg-999", groupName: "Payments",
+			want: map[string]string{"dev": "SO2C-dev", "prod": "PAY-prod", "uat": "PAY-uat"},
+		},
+		{
+			name:    "id wins over name",
+			groupId: "bg-123", groupName: "Payments",
+			want: map[string]string{"dev": "SO2C-dev", "prod": "PAY-prod", "uat": "PAY-uat-2"},
+		},
+		{
+			name: "no business group",
+			want: map[string]string{"dev": "SO2C-dev", "prod": "SO2C-prod"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := aliases.For(tc.groupId, tc.groupName); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("For(%q, %q) = %v, want %v", tc.groupId, tc.groupName, got, tc.want)
+			}
+		})
+	}
+
+	if got := Aliases(nil).For("bg-1", "Sales"); len(got) != 0 {
+		t.Errorf("For without aliases = %v, want none", got)
+	}
+}
@@ -0,0 +1,53 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/jchawla2804/golang-slack-event-listener/config"
+	"github.com/jchawla2804/golang-slack-event-listener/logging"
+	"github.com/slack-go/slack"
+)
+
+// NotifyConfigReload tells the admin channel of every workspace that the configuration was reloaded,
+// listing the settings and access rules that changed, or why the new configuration was rejected if err is not nil.
+func NotifyConfigReload(ctx context.Context, trigger string, changes []config.Change, err error) {
+	attachment := slack.Attachment{
+		Pretext: "Configuration reloaded (" + trigger + ")",
+		Color:   "#2eb886",
+	}
+	switch {
+	case err != nil:
+		attachment.Pretext = "Configuration reload rejected (" + trigger + ")"
+		attachment.Color = "#e01e5a"
+		attachment.Text = "The current configuration stays in effect.\n```" + logging.Redact(err.Error()) + "```"
+	case len(changes) == 0:
+		attachment.Text = "No settings or access rules changed."
+	default:
+		var lines []string
+		for _, change := range changes {
+			line := fmt.Sprintf("• `%s`: %q → %q", change.Setting, change.Old, change.New)
+			if change.NeedsRestart {
+				line += " _(takes effect after a restart)_"
+			}
+			lines = append(lines, line)
+		}
+		attachment.Text = strings.Join(lines, "\n")
+		if config.NeedsRestart(changes) {
+			attachment.Color = "#ecb22e"
+		}
+	}
+
+	for _, ws := range workspaces.List() {
+		channelId := ws.Settings().AdminChannelID
+		if channelId == "" {
+			continue
+		}
+		_, _, postErr := ws.Client.PostMessageContext(ctx, channelId, slack.MsgOptionAttachments(attachment))
+		if postErr != nil {
+			slog.ErrorContext(ctx, "Could not announce configuration reload", "workspace", ws.Name, "channel", channelId, "err", postErr)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/jchawla2804/golang-slack-event-listener/anypoint"
+	"github.com/jchawla2804/golang-slack-event-listener/approval"
+	"github.com/jchawla2804/golang-slack-event-listener/audit"
+	"github.com/jchawla2804/golang-slack-event-listener/database"
+	"github.com/jchawla2804/golang-slack-event-listener/logging"
+	"github.com/jchawla2804/golang-slack-event-listener/rbac"
+	"github.com/slack-go/slack"
+)
+
+// operationCommands maps each operation kind to the command that asks for it.
+var operationCommands = map[string]string{
+	approval.ChangeStatus: "/change-status",
+	approval.Scale:        "/scale-app",
+	approval.SetProperty:  "/set-property",
+}
+
+// Action ids of the buttons on approval requests.
+const (
+	ApproveActionID = "approval-approve"
+	RejectActionID  = "approval-reject"
+)
+
+var (
+	approvals = approval.NewStore(database.NewMemoryStore(), time.Hour)
+	// approvalsOptional is off until configured otherwise, so production changes fail closed.
+	approvalsOptional atomic.Bool
+)
+
+// errNoApprovers refuses a production change that needs approval in a workspace without an approvers channel.
+var errNoApprovers = errors.New("production changes need a second approver, but no approvers channel is configured for this workspace")
+
+// ConfigureApprovals makes approval requests that are not decided within window expire.
+// Production operations are sent for approval to the default channel of their workspace.
+// When approvals are not required, only workspaces with such a channel ask for them.
+// It may be called again while the bot is running.
+func ConfigureApprovals(window time.Duration, required bool) {
+	approvals.SetWindow(window)
+	approvalsOptional.Store(!required)
+}
+
+// approversChannel returns the channel approval requests of a team are posted in, or "" when there is none.
+func approversChannel(teamId string) string {
+	if ws, found := workspaces.Lookup(teamId); found {
+		return ws.Settings().DefaultChannelID
+	}
+	return ""
+}
+
+func approvalsEnabled(teamId string) bool {
+	return !approvalsOptional.Load() || approversChannel(teamId) != ""
+}
+
+// requestApproval puts an operation up for approval in the approvers channel and tells the requester.
+func requestApproval(ctx context.Context, slackClient *slack.Client, rc ResponseContext, op approval.Operation) error {
+	approversChannelId := approversChannel(op.TeamID)
+	if approversChannelId == "" {
+		slog.WarnContext(ctx, "Refused production change without an approvers channel", "team", op.TeamID, "operation", describeOperation(op))
+		return errNoApprovers
+	}
+
+	req, err := approvals.Create(op, rc.UserID)
+	if err != nil {
+		return err
+	}
+	req.ReplyChannelID = rc.ChannelID
+	req.ReplyThreadTS = rc.ThreadTS
+	req.ApproversChannelID = approversChannelId
+
+	_, timestamp, err := slackClient.PostMessageContext(ctx, req.ApproversChannelID, slack.MsgOptionBlocks(approvalBlocks(req)...))
+	if err != nil {
+		return err
+	}
+	req.MessageTS = timestamp
+	err = approvals.Save(req)
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Approval requested", "approval", req.ID, "operation", describeOperation(op))
+	recordApprovalAudit(req, rc.UserID, "approval-requested", 0, nil, nil)
+	attachment := slack.Attachment{
+		Pretext: "Sent for approval",
+		Text:    fmt.Sprintf("%s needs a second approver. The request expires at %s.", describeOperation(op), req.ExpiresAt.Format(time.RFC1123)),
+	}
+	return rc.Ephemeral(slackClient, slack.MsgOptionAttachments(attachment))
+}
+
+// HandleApprovalDecision approves or rejects a request when one of its buttons is clicked.
+// Only users the access policy allows to run the operation themselves may decide on it.
+// Approved operations run right away with the requester's session.
+func HandleApprovalDecision(ctx context.Context, slackClient *slack.Client, rc ResponseContext, requestId string, approve bool) error {
+	pending, err := approvals.Get(requestId)
+	if err != nil {
+		return err
+	}
+	command := operationCommands[pending.Kind]
+	resource := rbac.Resource{
+		Command:           command,
+		Environment:       pending.EnvName,
+		Production:        true,
+		BusinessGroupID:   pending.BusinessGroupID,
+		BusinessGroupName: pending.BusinessGroupName,
+	}
+	if _, allowed := permitted(ctx, rc.TeamID, rc.UserID, resource); !allowed {
+		slog.WarnContext(ctx, "Approval decision denied", "approval", pending.ID, "env", pending.EnvName)
+		return &PermissionError{Command: command, Environment: pending.EnvName}
+	}
+
+	req, err := approvals.Decide(requestId, rc.UserID, approve)
+	switch {
+	case errors.Is(err, approval.ErrSelfApproval):
+		return rc.Ephemeral(slackClient, slack.MsgOptionText("You cannot approve your own request. Someone else has to approve it.", false))
+	case errors.Is(err, approval.ErrExpired):
+		updateApprovalMessage(slackClient, req)
+		return rc.Ephemeral(slackClient, slack.MsgOptionText("This request has expired. Please ask the requester to run the command again.", false))
+	case errors.Is(err, approval.ErrNotPending):
+		return rc.Ephemeral(slackClient, slack.MsgOptionText(fmt.Sprintf("This request has already been %s by <@%s>.", req.State, req.DecidedBy), false))
+	case err != nil:
+		return err
+	}
+
+	slog.InfoContext(ctx, "Approval decided", "approval", req.ID, "state", req.State)
+	if req.State == approval.Approved {
+		ctx, requestIds := anypoint.WithRequestIDs(ctx)
+		start := time.Now()
+		err = executeApproved(ctx, req)
+		recordApprovalAudit(req, rc.UserID, "approval-approved", time.Since(start), requestIds.List(), err)
+		if finishErr := approvals.Finish(req, err); finishErr != nil {
+			slog.ErrorContext(ctx, "Could not save outcome of approval", "approval", req.ID, "err", finishErr)
+		}
+	} else {
+		recordApprovalAudit(req, rc.UserID, "approval-rejected", 0, nil, nil)
+	}
+
+	updateApprovalMessage(slackClient, req)
+	return notifyRequester(slackClient, req)
+}
+
+// WatchApprovals marks requests as expired once their window has passed, until ctx is done.
+func WatchApprovals(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := approvals.ExpirePending()
+			if err != nil {
+				slog.ErrorContext(ctx, "Could not expire approval requests", "err", err)
+			}
+			for _, req := range expired {
+				slog.InfoContext(ctx, "Approval expired", "approval", req.ID, "user", req.RequestedBy)
+				recordApprovalAudit(req, req.RequestedBy, "approval-expired", 0, nil, nil)
+				slackClient := slackClientOf(req.TeamID)
+				if slackClient == nil {
+					slog.WarnContext(ctx, "Not connected to the workspace of an expired approval", "approval", req.ID, "team", req.TeamID)
+					continue
+				}
+				updateApprovalMessage(slackClient, req)
+				if err := notifyRequester(slackClient, req); err != nil {
+					slog.ErrorContext(ctx, "Could not tell user about expired approval", "approval", req.ID, "user", req.RequestedBy, "err", err)
+				}
+			}
+		}
+	}
+}
+
+func executeApproved(ctx context.Context, req *approval.Request) error {
+	sess, err := sessionFor(req.TeamID, req.RequestedBy, req.BusinessGroupID)
+	if err != nil {
+		return fmt.Errorf("the requester must log in to Anypoint Platform again and repeat the request: %w", err)
+	}
+
+	return executeOperation(ctx, sessions.Client(sess), req.Operation)
+}
+
+func executeOperation(ctx context.Context, client *anypoint.Client, op approval.Operation) error {
+	switch op.Kind {
+	case approval.ChangeStatus:
+		_, err := client.ChangeAppStatus(ctx, op.Params["status"], op.EnvID, op.BusinessGroupID, op.AppName)
+		return err
+	case approval.Scale:
+		workers, err := strconv.Atoi(op.Params["workers"])
+		if err != nil {
+			return err
+		}
+		return client.ScaleApp(ctx, op.EnvID, op.BusinessGroupID, op.AppName, workers)
+	case approval.SetProperty:
+		return client.SetAppProperty(ctx, op.EnvID, op.BusinessGroupID, op.AppName, op.Params["name"], op.Params["value"])
+	default:
+		return fmt.Errorf("unknown operation %s", op.Kind)
+	}
+}
+
+// recordApprovalAudit records a step of an approval. The value of a property is left out,
+// as it may be secret and approvals outlive the redactor's memory of secrets.
+func recordApprovalAudit(req *approval.Request, userId, action string, latency time.Duration, requestIds []string, err error) {
+	params := maps.Clone(req.Params)
+	if _, found := params["value"]; found {
+		params["value"] = logging.Redacted
+	}
+	entry := audit.Entry{
+		TeamID:          req.TeamID,
+		UserID:          userId,
+		Action:          action,
+		Text:            fmt.Sprintf("%s %v (approval %s requested by %s)", req.Kind, params, req.ID, req.RequestedBy),
+		BusinessGroupID: req.BusinessGroupID,
+		EnvName:         req.EnvName,
+		EnvID:           req.EnvID,
+		AppName:         req.AppName,
+		Latency:         latency,
+		RequestIDs:      requestIds,
+	}
+	auditResult(&entry, err)
+	recordAudit(entry)
+}
+
+func describeOperation(op approval.Operation) string {
+	switch op.Kind {
+	case approval.ChangeStatus:
+		return fmt.Sprintf("*%s* application *%s* in *%s*", op.Params["status"], op.AppName, op.EnvName)
+	case approval.Scale:
+		return fmt.Sprintf("*scale* application *%s* in *%s* to %s workers", op.AppName, op.EnvName, op.Params["workers"])
+	case approval.SetProperty:
+		return fmt.Sprintf("*set property* `%s` of application *%s* in *%s*", op.Params["name"], op.AppName, op.EnvName)
+	default:
+		return op.Kind + " " + op.AppName
+	}
+}
+
+func approvalBlocks(req *approval.Request) []slack.Block {
+	text := fmt.Sprintf("<@%s> wants to %s.", req.RequestedBy, describeOperation(req.Operation))
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+	}
+
+	var status string
+	switch req.State {
+	case approval.Pending:
+		blocks = append(blocks, slack.NewActionBlock("approval-"+req.ID,
+			slack.NewButtonBlockElement(ApproveActionID, req.ID, slack.NewTextBlockObject(slack.PlainTextType, "Approve", false, false)).WithStyle(slack.StylePrimary),
+			slack.NewButtonBlockElement(RejectActionID, req.ID, slack.NewTextBlockObject(slack.PlainTextType, "Reject", false, false)).WithStyle(slack.StyleDanger),
+		))
+		status = "Expires at " + req.ExpiresAt.Format(time.RFC1123)
+	case approval.Expired:
+		status = ":hourglass: Expired without a decision"
+	case approval.Rejected:
+		status = fmt.Sprintf(":x: Rejected by <@%s>", req.DecidedBy)
+	case approval.Executed:
+		status = fmt.Sprintf(":white_check_mark: Approved by <@%s> and executed", req.DecidedBy)
+	case approval.Failed:
+		status = fmt.Sprintf(":warning: Approved by <@%s> but failed: %s", req.DecidedBy, req.Result)
+	default:
+		status = fmt.Sprintf("Approved by <@%s>", req.DecidedBy)
+	}
+
+	return append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, status, false, false)))
+}
+
+func updateApprovalMessage(slackClient *slack.Client, req *approval.Request) {
+	if req == nil || req.MessageTS == "" {
+		return
+	}
+	_, _, _, err := slackClient.UpdateMessage(req.ApproversChannelID, req.MessageTS, slack.MsgOptionBlocks(approvalBlocks(req)...))
+	if err != nil {
+		slog.Error("Could not update approval message", "approval", req.ID, "err", err)
+	}
+}
+
+func notifyRequester(slackClient *slack.Client, req *approval.Request) error {
+	rc := ResponseContext{TeamID: req.TeamID, ChannelID: req.ReplyChannelID, ThreadTS: req.ReplyThreadTS, UserID: req.RequestedBy}
+
+	var text string
+	switch req.State {
+	case approval.Executed:
+		text = fmt.Sprintf("Your request to %s was approved by <@%s> and executed.", describeOperation(req.Operation), req.DecidedBy)
+	case approval.Failed:
+		text = fmt.Sprintf("Your request to %s was approved by <@%s> but failed: %s", describeOperation(req.Operation), req.DecidedBy, req.Result)
+	case approval.Rejected:
+		text = fmt.Sprintf("Your request to %s was rejected by <@%s>.", describeOperation(req.Operation), req.DecidedBy)
+	case approval.Expired:
+		text = fmt.Sprintf("Your request to %s expired without a decision.", describeOperation(req.Operation))
+	default:
+		return nil
+	}
+	return rc.Reply(slackClient, slack.MsgOptionText(text, false))
+}
@@ -0,0 +1,194 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jchawla2804/golang-slack-event-listener/anypoint"
+	"github.com/jchawla2804/golang-slack-event-listener/audit"
+	"github.com/jchawla2804/golang-slack-event-listener/logging"
+	"github.com/slack-go/slack"
+)
+
+var auditLog *audit.Log
+
+// UseAuditLog records every command and state-changing action in l.
+func UseAuditLog(l *audit.Log) {
+	auditLog = l
+}
+
+func init() {
+	Commands.Register(Command{
+		Name:        "/audit",
+		Description: "Searches the audit log",
+		Flags: []Flag{
+			{Name: "user", Description: "only entries of this user"},
+			{Name: "app", Description: "only entries for this application"},
+			{Name: "since", Description: "start of the time range, e.g. 24h, 7d or 2024-01-31", Default: "24h"},
+			{Name: "until", Description: "end of the time range, same format as --since"},
+			{Name: "limit", Description: "maximum number of entries", Default: "20"},
+		},
+		Handler: handleAudit,
+	})
+}
+
+// recordAudit appends an entry to the audit log if one is configured.
+// Secrets are scrubbed from the text and error of the entry first. Callers must leave out
+// secret values themselves: the redactor only knows the secrets seen since the bot started.
+func recordAudit(entry audit.Entry) {
+	if auditLog == nil {
+		return
+	}
+	entry.Text = logging.Redact(entry.Text)
+	entry.Error = logging.Redact(entry.Error)
+	if err := auditLog.Record(entry); err != nil {
+		slog.Error("Could not write audit entry", "user", entry.UserID, "action", entry.Action, "err", err)
+	}
+}
+
+// auditResult classifies the outcome of an action for the audit log.
+func auditResult(entry *audit.Entry, err error) {
+	var permissionErr *PermissionError
+	switch {
+	case err == nil:
+		entry.Result = audit.ResultOK
+	case errors.As(err, &permissionErr):
+		entry.Result = audit.ResultDenied
+	default:
+		entry.Result = audit.ResultError
+		entry.Error = err.Error()
+	}
+}
+
+// AuditCommands records every command with its outcome, latency and the Anypoint request ids it caused.
+func AuditCommands(cmd *Command, next HandlerFunc) HandlerFunc {
+	return func(req *Request) error {
+		ctx, requestIds := anypoint.WithRequestIDs(req.Ctx)
+		req.Ctx = ctx
+
+		start := time.Now()
+		err := next(req)
+
+		entry := audit.Entry{
+			TeamID:     req.Command.TeamID,
+			UserID:     req.Command.UserID,
+			Action:     "command",
+			Command:    cmd.Name,
+			Text:       req.Args.Masked(),
+			EnvName:    req.Args.Get("environment"),
+			EnvID:      req.Environment.ID,
+			AppName:    req.Args.Get("application"),
+			Latency:    time.Since(start),
+			RequestIDs: requestIds.List(),
+		}
+		if req.Session != nil {
+			entry.BusinessGroupID = req.Session.BusinessGroupID
+		}
+		if req.Environment.Name != "" {
+			entry.EnvName = req.Environment.Name
+		}
+		auditResult(&entry, err)
+		recordAudit(entry)
+		return err
+	}
+}
+
+// auditInvalidArgs records a command whose arguments could not be parsed. The text is left out,
+// as it cannot be told which part of it is a secret.
+func auditInvalidArgs(cmd *Command, command slack.SlashCommand, err error) {
+	entry := audit.Entry{
+		TeamID:  command.TeamID,
+		UserID:  command.UserID,
+		Action:  "command",
+		Command: cmd.Name,
+	}
+	auditResult(&entry, err)
+	recordAudit(entry)
+}
+
+func handleAudit(req *Request) error {
+	if auditLog == nil {
+		return errors.New("The audit log is not enabled")
+	}
+	cmd, _ := Commands.Lookup(req.Command.Command)
+
+	// Only entries of the caller's workspace are searched.
+	query := audit.Query{
+		TeamID:  req.Command.TeamID,
+		UserID:  slackUserID(req.Args.Flag("user")),
+		AppName: req.Args.Flag("app"),
+	}
+	var err error
+	query.Limit, err = strconv.Atoi(req.Args.Flag("limit"))
+	if err != nil || query.Limit < 1 {
+		return &UsageError{Command: cmd, Reason: "--limit must be a positive number"}
+	}
+	if query.Since, err = parseAuditTime(req.Args.Flag("since")); err != nil {
+		return &UsageError{Command: cmd, Reason: err.Error()}
+	}
+	if query.Until, err = parseAuditTime(req.Args.Flag("until")); err != nil {
+		return &UsageError{Command: cmd, Reason: err.Error()}
+	}
+
+	entries, err := auditLog.Search(query)
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	for _, entry := range entries {
+		line := fmt.Sprintf("`%s` <@%s> %s", entry.Time.Format("2006-01-02 15:04:05"), entry.UserID, entry.Action)
+		if entry.Command != "" {
+			line += " `" + strings.TrimSpace(entry.Command+" "+entry.Text) + "`"
+		}
+		if entry.AppName != "" {
+			line += fmt.Sprintf(" app=%s env=%s", entry.AppName, entry.EnvName)
+		}
+		line += fmt.Sprintf(" → %s (%s)", entry.Result, entry.Latency.Round(time.Millisecond))
+		if len(entry.RequestIDs) > 0 {
+			line += " request ids " + strings.Join(entry.RequestIDs, ", ")
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		lines = []string{"No audit entries match."}
+	}
+
+	attachment := slack.Attachment{
+		Pretext: fmt.Sprintf("Audit log (%d entries)", len(entries)),
+		Text:    strings.Join(lines, "\n"),
+	}
+	return req.Response.Ephemeral(req.SlackClient, slack.MsgOptionAttachments(attachment))
+}
+
+// slackUserID extracts the user id from a mention like <@U123|name>.
+func slackUserID(value string) string {
+	value = strings.TrimPrefix(strings.TrimSuffix(value, ">"), "<@")
+	id, _, _ := strings.Cut(value, "|")
+	return id
+}
+
+// parseAuditTime reads a time given as an age (30m, 24h, 7d), a date or an RFC 3339 timestamp.
+func parseAuditTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if days, found := strings.CutSuffix(value, "d"); found {
+		if n, err := strconv.Atoi(days); err == nil {
+			return time.Now().AddDate(0, 0, -n), nil
+		}
+	}
+	if age, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-age), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Invalid time %q", value)
+}
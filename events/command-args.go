@@ -0,0 +1,270 @@
+package events
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/jchawla2804/golang-slack-event-listener/logging"
+)
+
+// Flag describes a --name or --name=value option of a command.
+type Flag struct {
+	Name        string
+	Description string
+	Default     string
+	// Bool flags take no value; they are "true" when present.
+	Bool bool
+}
+
+// Args holds the parsed arguments of a slash command.
+type Args struct {
+	positional map[string]string
+	flags      map[string]string
+	masked     string
+}
+
+// Get returns a positional argument or its default.
+func (a Args) Get(name string) string {
+	return a.positional[name]
+}
+
+// Flag returns the value of a flag or its default.
+func (a Args) Flag(name string) string {
+	return a.flags[name]
+}
+
+// Bool reports whether a bool flag was given.
+func (a Args) Bool(name string) bool {
+	return a.flags[name] == "true"
+}
+
+// Masked returns the text of the command with the values of secret arguments replaced,
+// so it can be logged and audited.
+func (a Args) Masked() string {
+	return a.masked
+}
+
+// UsageError is returned when a command is called with arguments that do not match its schema.
+type UsageError struct {
+	Command *Command
+	Reason  string
+}
+
+func (e *UsageError) Error() string {
+	return e.Reason + "\n" + e.Command.UsageText()
+}
+
+// UsageText describes how to call the command, including its arguments and flags.
+func (c *Command) UsageText() string {
+	usage := c.Usage
+	if usage == "" {
+		parts := []string{c.Name}
+		for _, arg := range c.Args {
+			if arg.Required {
+				parts = append(parts, "<"+arg.Name+">")
+			} else {
+				parts = append(parts, "["+arg.Name+"]")
+			}
+		}
+		for _, flag := range c.Flags {
+			if flag.Bool {
+				parts = append(parts, "[--"+flag.Name+"]")
+			} else {
+				parts = append(parts, "[--"+flag.Name+" <value>]")
+			}
+		}
+		usage = strings.Join(parts, " ")
+	}
+
+	lines := []string{"Usage: `" + usage + "`"}
+	for _, arg := range c.Args {
+		line := fmt.Sprintf("  %s: %s", arg.Name, arg.Description)
+		if len(arg.Choices) > 0 {
+			line += " (one of " + strings.Join(arg.Choices, ", ") + ")"
+		}
+		if arg.Default != "" {
+			line += " (default " + arg.Default + ")"
+		}
+		lines = append(lines, line)
+	}
+	for _, flag := range c.Flags {
+		line := fmt.Sprintf("  --%s: %s", flag.Name, flag.Description)
+		if flag.Default != "" {
+			line += " (default " + flag.Default + ")"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ParseArgs parses the text of a slash command against the argument schema of the command.
+// Values may be quoted with single, double or curly quotes to include spaces.
+// It returns a *UsageError when the text does not match the schema.
+func (c *Command) ParseArgs(text string) (Args, error) {
+	args := Args{positional: map[string]string{}, flags: map[string]string{}}
+	for _, flag := range c.Flags {
+		if flag.Default != "" {
+			args.flags[flag.Name] = flag.Default
+		}
+	}
+
+	tokens, err := splitArgs(text)
+	if err != nil {
+		return args, &UsageError{Command: c, Reason: err.Error()}
+	}
+
+	var values []string
+	// positions holds the index in tokens of every value, to mask secret arguments.
+	var positions []int
+	flagsDone := false
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if flagsDone || !strings.HasPrefix(token, "--") {
+			values = append(values, token)
+			positions = append(positions, i)
+			continue
+		}
+		if token == "--" {
+			flagsDone = true
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(token, "--"), "=")
+		flag := c.flag(name)
+		if flag == nil {
+			return args, &UsageError{Command: c, Reason: "Unknown flag --" + name}
+		}
+		switch {
+		case flag.Bool && hasValue:
+			return args, &UsageError{Command: c, Reason: "Flag --" + name + " takes no value"}
+		case flag.Bool:
+			value = "true"
+		case !hasValue:
+			if i+1 >= len(tokens) {
+				return args, &UsageError{Command: c, Reason: "Flag --" + name + " needs a value"}
+			}
+			i++
+			value = tokens[i]
+		}
+		args.flags[name] = value
+	}
+
+	if len(values) > len(c.Args) {
+		return args, &UsageError{Command: c, Reason: fmt.Sprintf("Too many arguments: %s", strings.Join(values[len(c.Args):], " "))}
+	}
+	for i, arg := range c.Args {
+		value := arg.Default
+		if i < len(values) {
+			value = values[i]
+		} else if arg.Required {
+			return args, &UsageError{Command: c, Reason: "Missing argument <" + arg.Name + ">"}
+		}
+		if value != "" && len(arg.Choices) > 0 && !slices.Contains(arg.Choices, strings.ToLower(value)) {
+			return args, &UsageError{Command: c, Reason: fmt.Sprintf("Invalid %s %q", arg.Name, value)}
+		}
+		if len(arg.Choices) > 0 {
+			value = strings.ToLower(value)
+		}
+		if arg.Secret && i < len(values) {
+			tokens[positions[i]] = logging.Redacted
+		}
+		args.positional[arg.Name] = value
+	}
+	args.masked = joinArgs(tokens)
+	return args, nil
+}
+
+func (c *Command) hasArg(name string) bool {
+	for _, arg := range c.Args {
+		if arg.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Command) flag(name string) *Flag {
+	for i := range c.Flags {
+		if c.Flags[i].Name == name {
+			return &c.Flags[i]
+		}
+	}
+	return nil
+}
+
+// Slack HTML-escapes &, < and > in the text of slash commands and expects them escaped in messages.
+var (
+	slackUnescaper = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&")
+	slackEscaper   = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+)
+
+// closingQuotes maps each opening quote to the one that ends it. Slack clients may turn typed
+// quotes into curly ones, so a curly quote is only closed by its curly pair.
+var closingQuotes = map[rune]rune{'"': '"', '\'': '\'', '\u201c': '\u201d', '\u2018': '\u2019'}
+
+// joinArgs is the reverse of splitArgs, quoting tokens where needed.
+func joinArgs(tokens []string) string {
+	quoted := make([]string, len(tokens))
+	for i, token := range tokens {
+		if token == "" || strings.ContainsFunc(token, func(r rune) bool { return unicode.IsSpace(r) || r == '"' || r == '\'' || r == '\\' }) {
+			token = strconv.Quote(token)
+		}
+		quoted[i] = token
+	}
+	return strings.Join(quoted, " ")
+}
+
+// splitArgs splits the text of a slash command on whitespace, keeping quoted values together.
+// Inside double quotes a backslash escapes the next character.
+func splitArgs(text string) ([]string, error) {
+	text = slackUnescaper.Replace(text)
+
+	var tokens []string
+	var current strings.Builder
+	inToken := false
+	var quote, closing rune
+	escaped := false
+
+	for _, r := range text {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case (quote == '"' || quote == '\u201c') && r == '\\':
+			escaped = true
+		case quote != 0 && r == closing:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\u201c' || (r == '\'' || r == '\u2018') && opensQuote(inToken, current.String()):
+			quote, closing = r, closingQuotes[r]
+			inToken = true
+		case unicode.IsSpace(r):
+			if inToken {
+				tokens = append(tokens, current.String())
+				current.Reset()
+				inToken = false
+			}
+		default:
+			current.WriteRune(r)
+			inToken = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("Unterminated %c quote", quote)
+	}
+	if inToken {
+		tokens = append(tokens, current.String())
+	}
+	return tokens, nil
+}
+
+// opensQuote reports whether a single quote starts a quoted value: at the start of a token or a
+// flag value. Inside a word it is an apostrophe, as in --reason it's broken.
+func opensQuote(inToken bool, token string) bool {
+	return !inToken || strings.HasSuffix(token, "=")
+}
@@ -0,0 +1,199 @@
+package events
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testCommand = &Command{
+	Name: "/test",
+	Args: []Arg{
+		{Name: "environment", Required: true},
+		{Name: "status", Required: true, Choices: []string{"start", "stop", "restart"}},
+		{Name: "app", Default: "all"},
+	},
+	Flags: []Flag{
+		{Name: "reason"},
+		{Name: "timeout", Default: "30s"},
+		{Name: "force", Bool: true},
+	},
+}
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		args  map[string]string
+		flags map[string]string
+		err   string
+	}{
+		{
+			name:  "defaults",
+			text:  "dev stop",
+			args:  map[string]string{"environment": "dev", "status": "stop", "app": "all"},
+			flags: map[string]string{"timeout": "30s"},
+		},
+		{
+			name:  "all arguments",
+			text:  "  dev   START  orders-api ",
+			args:  map[string]string{"environment": "dev", "status": "start", "app": "orders-api"},
+			flags: map[string]string{"timeout": "30s"},
+		},
+		{name: "no arguments", text: "", err: "Missing argument <environment>"},
+		{name: "missing argument", text: "dev", err: "Missing argument <status>"},
+		{name: "too many arguments", text: "dev stop app extra more", err: "Too many arguments: extra more"},
+		{name: "invalid choice", text: "dev pause", err: `Invalid status "pause"`},
+		{
+			name:  "double quotes",
+			text:  `"my env" stop "orders api"`,
+			args:  map[string]string{"environment": "my env", "status": "stop", "app": "orders api"},
+			flags: map[string]string{"timeout": "30s"},
+		},
+		{
+			name:  "single quotes",
+			text:  `'my env' stop 'say "hi"'`,
+			args:  map[string]string{"environment": "my env", "status": "stop", "app": `say "hi"`},
+			flags: map[string]string{"timeout": "30s"},
+		},
+		{
+			name:  "curly quotes",
+			text:  "“my env” stop ‘orders api’",
+			args:  map[string]string{"environment": "my env", "status": "stop", "app": "orders api"},
+			flags: map[string]string{"timeout": "30s"},
+		},
+		{
+			name:  "apostrophes inside words",
+			text:  "dev stop --reason it’s --timeout=1m o'brien",
+			args:  map[string]string{"environment": "dev", "status": "stop", "app": "o'brien"},
+			flags: map[string]string{"reason": "it’s", "timeout": "1m"},
+		},
+		{
+			name:  "apostrophe inside quotes",
+			text:  "dev stop --reason “it’s broken”",
+			args:  map[string]string{"environment": "dev", "status": "stop", "app": "all"},
+			flags: map[string]string{"reason": "it’s broken", "timeout": "30s"},
+		},
+		{
+			name:  "single quoted flag value",
+			text:  "dev stop --reason='planned work'",
+			args:  map[string]string{"environment": "dev", "status": "stop", "app": "all"},
+			flags: map[string]string{"reason": "planned work", "timeout": "30s"},
+		},
+		{name: "unpaired curly quote", text: "dev stop “orders", err: "Unterminated “ quote"},
+		{
+			name:  "empty quotes",
+			text:  `dev stop ""`,
+			args:  map[string]string{"environment": "dev", "status": "stop", "app": ""},
+			flags: map[string]string{"timeout": "30s"},
+		},
+		{
+			name:  "backslash escapes",
+			text:  `dev stop "a \"quoted\" \\ value"`,
+			args:  map[string]string{"environment": "dev", "status": "stop", "app": `a "quoted" \ value`},
+			flags: map[string]string{"timeout": "30s"},
+		},
+		{name: "unterminated quote", text: `dev stop "orders`, err: `Unterminated " quote`},
+		{
+			name:  "html escapes",
+			text:  `dev stop "a&amp;b &lt;c&gt;"`,
+			args:  map[string]string{"environment": "dev", "status": "stop", "app": "a&b <c>"},
+			flags: map[string]string{"timeout": "30s"},
+		},
+		{
+			name:  "escaped entity",
+			text:  `dev stop a&amp;lt;b`,
+			args:  map[string]string{"environment": "dev", "status": "stop", "app": "a&lt;b"},
+			flags: map[string]string{"timeout": "30s"},
+		},
+		{
+			name:  "flag with equals",
+			text:  "dev stop --reason=maintenance --timeout=1m",
+			args:  map[string]string{"environment": "dev", "status": "stop", "app": "all"},
+			flags: map[string]string{"reason": "maintenance", "timeout": "1m"},
+		},
+		{
+			name:  "flag with separate value",
+			text:  `--reason "planned maintenance" dev stop`,
+			args:  map[string]string{"environment": "dev", "status": "stop", "app": "all"},
+			flags: map[string]string{"reason": "planned maintenance", "timeout": "30s"},
+		},
+		{
+			name:  "bool flag",
+			text:  "dev --force stop",
+			args:  map[string]string{"environment": "dev", "status": "stop", "app": "all"},
+			flags: map[string]string{"timeout": "30s", "force": "true"},
+		},
+		{name: "bool flag with value", text: "dev stop --force=yes", err: "Flag --force takes no value"},
+		{name: "flag without value", text: "dev stop --reason", err: "Flag --reason needs a value"},
+		{name: "unknown flag", text: "dev stop --verbose", err: "Unknown flag --verbose"},
+		{
+			name:  "double dash ends flags",
+			text:  "dev stop -- --force",
+			args:  map[string]string{"environment": "dev", "status": "stop", "app": "--force"},
+			flags: map[string]string{"timeout": "30s"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			args, err := testCommand.ParseArgs(tc.text)
+			if tc.err != "" {
+				var usageErr *UsageError
+				if !errors.As(err, &usageErr) {
+					t.Fatalf("ParseArgs(%q) = %v, want a usage error", tc.text, err)
+				}
+				if usageErr.Reason != tc.err {
+					t.Fatalf("ParseArgs(%q) reason = %q, want %q", tc.text, usageErr.Reason, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseArgs(%q) = %v", tc.text, err)
+			}
+			if !reflect.DeepEqual(args.positional, tc.args) {
+				t.Errorf("ParseArgs(%q) args = %v, want %v", tc.text, args.positional, tc.args)
+			}
+			if !reflect.DeepEqual(args.flags, tc.flags) {
+				t.Errorf("ParseArgs(%q) flags = %v, want %v", tc.text, args.flags, tc.flags)
+			}
+		})
+	}
+}
+
+func TestUsageErrorIsEscapedForSlack(t *testing.T) {
+	_, err := testCommand.ParseArgs("")
+	text := errorText(err)
+	if strings.ContainsAny(text, "<>") {
+		t.Errorf("error text %q is not escaped", text)
+	}
+	if !strings.Contains(text, "Missing argument &lt;environment&gt;") {
+		t.Errorf("error text %q does not name the missing argument", text)
+	}
+}
+
+func TestArgsMaskSecrets(t *testing.T) {
+	cmd := &Command{
+		Name:  "/set",
+		Args:  []Arg{{Name: "name", Required: true}, {Name: "value", Required: true, Secret: true}},
+		Flags: []Flag{{Name: "reason"}},
+	}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{`db.password "s3cr3t pw"`, "db.password [REDACTED]"},
+		{`--reason "new db" db.password abc`, `--reason "new db" db.password [REDACTED]`},
+		{`db.password -- --abc`, "db.password -- [REDACTED]"},
+	}
+	for _, tc := range tests {
+		args, err := cmd.ParseArgs(tc.text)
+		if err != nil {
+			t.Fatalf("ParseArgs(%q) = %v", tc.text, err)
+		}
+		if got := args.Masked(); got != tc.want {
+			t.Errorf("ParseArgs(%q).Masked() = %q, want %q", tc.text, got, tc.want)
+		}
+	}
+}
@@ -1,220 +1,277 @@
 package events
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/jchawla2804/golang-slack-event-listener/approval"
 	"github.com/jchawla2804/golang-slack-event-listener/database"
-	"github.com/jchawla2804/golang-slack-event-listener/helper"
+	"github.com/jchawla2804/golang-slack-event-listener/metrics"
+	"github.com/jchawla2804/golang-slack-event-listener/session"
+	"github.com/jchawla2804/golang-slack-event-listener/workspace"
 	"github.com/slack-go/slack"
-	"github.com/slack-go/slack/slackevents"
-	"golang.org/x/exp/slices"
 )
 
 var (
-	cacheClient = database.CreateCache()
-	//SlackContext, cancel = context.WithCancel(context.Background())
+	stateStore database.Store = database.NewMemoryStore()
+	sessions                  = session.NewManager(stateStore)
+	workspaces *workspace.Registry
 )
 
-func HandlePlatformInformation(slackClient *slack.Client, businessGroup, businessGroupId string) error {
-	cacheClient.Add("business_group_id", businessGroupId, 10*time.Hour)
-	cacheClient.Add("business_group_name", businessGroup, 10*time.Hour)
+// UseStore makes the handlers keep sessions and approvals in the given store instead of process memory.
+func UseStore(store database.Store) {
+	stateStore = store
+	sessions = session.NewManager(store)
+	approvals = approval.NewStore(store, time.Hour)
+}
+
+// ConfigureSessions caps how long connected app logins re-mint their tokens; 0 means no cap.
+// It may be called again while the bot is running.
+func ConfigureSessions(connectedAppLifetime time.Duration) {
+	sessions.SetConnectedAppLifetime(connectedAppLifetime)
+}
+
+// UseWorkspaces gives the handlers the Slack workspaces the bot is connected to,
+// for the settings and Slack clients of each workspace.
+func UseWorkspaces(r *workspace.Registry) {
+	workspaces = r
+}
+
+// slackClientOf returns the Slack client of a team's workspace, or nil if the bot is not connected to it.
+func slackClientOf(teamId string) *slack.Client {
+	if ws, found := workspaces.Lookup(teamId); found {
+		return ws.Client
+	}
+	return nil
+}
+
+func init() {
+	Commands.Register(Command{
+		Name:         "/get-status",
+		Description:  "Shows the status of all applications in an environment",
+		Args:         []Arg{{Name: "environment", Description: "environment name", Required: true}},
+		NeedsSession: true,
+		Handler:      handleGetStatus,
+	})
+	Commands.Register(Command{
+		Name:        "/change-status",
+		Description: "Starts, stops or restarts an application",
+		Args: []Arg{
+			{Name: "status", Description: "new status of the application", Required: true, Choices: []string{"start", "stop", "restart"}},
+			{Name: "environment", Description: "environment name", Required: true},
+			{Name: "application", Description: "application name", Required: true},
+		},
+		NeedsSession: true,
+		Handler:      handleChangeStatus,
+	})
+	Commands.Register(Command{
+		Name:         "/get-asset-info",
+		Description:  "Lists the Exchange assets of the organization",
+		NeedsSession: true,
+		Handler:      handleGetAssetInfo,
+	})
+	Commands.Register(Command{
+		Name:         "/list-environments",
+		Description:  "Lists the environments of the selected business group",
+		NeedsSession: true,
+		Handler:      handleListEnvironments,
+	})
+	Commands.Register(Command{
+		Name:         "/download-asset",
+		Description:  "Uploads an Exchange asset to the channel",
+		Args:         []Arg{{Name: "asset", Description: "asset id in Exchange", Required: true}},
+		NeedsSession: true,
+		Handler:      handleDownloadAsset,
+	})
+}
+
+// BusinessGroupBlockPrefix starts the block id of the business group menu sent after a login,
+// which ends with the id of the organization logged in to.
+const BusinessGroupBlockPrefix = "bg-block:"
+
+// HandlePlatformInformation makes the login a business group was chosen for act on it, and makes it active.
+func HandlePlatformInformation(ctx context.Context, slackClient *slack.Client, rc ResponseContext, blockId, businessGroupId string) error {
+	orgId, found := strings.CutPrefix(blockId, BusinessGroupBlockPrefix)
+	if !found || orgId == "" {
+		return errors.New("this business group menu is out of date, please log in again")
+	}
+	sess, err := sessions.GetOrg(rc.TeamID, rc.UserID, orgId)
+	if errors.Is(err, session.ErrNotLoggedIn) {
+		return PromptLogin(slackClient, rc, "You are no longer logged in to this organization.")
+	}
+	if err != nil {
+		return err
+	}
+	index := slices.IndexFunc(sess.BusinessGroups, func(group session.BusinessGroup) bool { return group.ID == businessGroupId })
+	if index < 0 {
+		return fmt.Errorf("business group %s is not part of %s", businessGroupId, sess.OrgName)
+	}
+	group := sess.BusinessGroups[index]
+
+	sess.UseBusinessGroup(group)
+	err = sessions.Save(sess)
+	if err == nil {
+		err = sessions.Activate(sess)
+	}
+	if err != nil {
+		return err
+	}
 
 	attachment := slack.Attachment{
 		Pretext: "Business Group Information",
 		Color:   "#36a64f",
-		Text:    "Business Group Name: " + businessGroup + "\nBusiness Group Id: " + businessGroupId,
+		Text:    "Business Group Name: " + group.Name + "\nBusiness Group Id: " + group.ID + "\nOrganization: " + sess.OrgName,
 	}
 
-	_, timestamp, err := slackClient.PostMessage(
-		os.Getenv("CHANNEL_ID"),
-		slack.MsgOptionAttachments(attachment),
-	)
+	return rc.Ephemeral(slackClient, slack.MsgOptionAttachments(attachment))
+}
+
+// HandleSlackCommands answers a slash command with the command registered under its name.
+func HandleSlackCommands(ctx context.Context, slackClient *slack.Client, command slack.SlashCommand) error {
+	name := "unknown"
+	if cmd, found := Commands.Lookup(command.Command); found {
+		name = cmd.Name
+	}
 
+	start := time.Now()
+	err := Commands.Handle(ctx, slackClient, command)
+	metrics.Command(name, commandOutcome(err), time.Since(start))
+	return err
+}
+
+// ActiveSessions returns the number of users logged in to Anypoint Platform.
+func ActiveSessions() (int, error) {
+	return sessions.Count()
+}
+
+func commandOutcome(err error) string {
+	var usageErr *UsageError
+	var permissionErr *PermissionError
+	switch {
+	case err == nil:
+		return metrics.OutcomeOK
+	case errors.As(err, &usageErr):
+		return metrics.OutcomeUsageError
+	case errors.As(err, &permissionErr):
+		return metrics.OutcomeDenied
+	}
+	return metrics.OutcomeError
+}
+
+func handleGetStatus(req *Request) error {
+	appDetails, err := req.Anypoint.GetAppDetails(req.Ctx, req.Environment.ID, req.Session.BusinessGroupID)
 	if err != nil {
 		return err
 	}
 
-	log.Print("Message Sent at this time " + timestamp)
-	return nil
+	slackAttachment := slack.Attachment{
+		Text:    appDetails,
+		Pretext: fmt.Sprintf("App Details for %s environment ", req.Environment.Name),
+	}
+
+	return req.Response.Reply(req.SlackClient, slack.MsgOptionAttachments(slackAttachment))
 }
 
-func HandleSlackCommands(slackClient *slack.Client, command slack.SlashCommand) error {
-	tokenValue, status := cacheClient.Get("access_token")
-	if !status {
-		log.Println("Please login again")
-		return HandleSlackAppMentions(&slackevents.AppMentionEvent{User: command.UserID, Channel: command.ChannelID}, slackClient)
-	}
-	orgId, status := cacheClient.Get("business_group_id")
-	if !status {
-		log.Println("Please login again. Org ID not found")
-		return HandleSlackAppMentions(&slackevents.AppMentionEvent{User: command.UserID, Channel: command.ChannelID}, slackClient)
-
-	}
-
-	token := tokenValue.(string)
-
-	switch command.Command {
-	case "/get-status":
-
-		if !status {
-			log.Fatal("No access token is there. Please login")
-			return errors.New("Please login again")
-		}
-
-		log.Println(token)
-
-		envName := command.Text
-
-		envId, status := cacheClient.Get(envName)
-		if !status {
-			return errors.New("Please login again. Environeent ID not found for " + envName + " environment")
-		}
-
-		appDetails, err := helper.GetAppDetails(token, envId.(string), orgId.(string))
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		slackAttachment := slack.Attachment{
-			Text:    appDetails,
-			Pretext: fmt.Sprintf("App Details for %s environment ", envName),
-		}
-
-		_, _, err = slackClient.PostMessage(os.Getenv("CHANNEL_ID"), slack.MsgOptionAttachments(slackAttachment))
-		if err != nil {
-			return err
-		}
-
-	case "/change-status":
-
-		if !status {
-			log.Fatal("No access token is there. Please login")
-			return errors.New("Please login again")
-		}
-		listOfOptions := strings.Split(command.Text, " ")
-		envId, status := cacheClient.Get(listOfOptions[1])
-		if !status {
-			return errors.New("Please login again. Environeent ID not found for " + listOfOptions[1] + " environment")
-		}
-
-		log.Println(listOfOptions)
-		if !slices.Contains([]string{"stop", "start", "restart"}, listOfOptions[0]) {
-			PostMessage(os.Getenv("CHANNEL_ID"), slackClient)
-			return nil
-		} else {
-			_, err := helper.ChangeAppStatus(listOfOptions[0], token, envId.(string), orgId.(string), listOfOptions[2])
-
-			if err != nil {
-				log.Print(err.Error())
-				return err
-			}
-			slackAttachment := slack.Attachment{
-				Text:    "Status Of API " + listOfOptions[2] + " has changed to " + listOfOptions[0],
-				Pretext: "Status has changed",
-			}
-
-			_, _, err = slackClient.PostMessage(os.Getenv("CHANNEL_ID"), slack.MsgOptionAttachments(slackAttachment))
-			if err != nil {
-				return err
-			}
-
-		}
-
-	case "/get-asset-info":
-
-		if !status {
-			log.Fatal("No access token is there. Please login")
-			return errors.New("Please login again")
-		}
-
-		response, err := helper.GetAssetInfo(token)
-		if err != nil {
-			log.Println(err.Error())
-			return err
-		}
-
-		slackAttachment := slack.Attachment{
-			Text:    response,
-			Pretext: "Asset Information",
-		}
-
-		_, _, err = slackClient.PostMessage(os.Getenv("CHANNEL_ID"), slack.MsgOptionAttachments(slackAttachment))
-		if err != nil {
-			return err
-		}
-
-	case "/list-environments":
-		if !status {
-			log.Println("No access token is there. Please login")
-			return HandleSlackAppMentions(&slackevents.AppMentionEvent{User: command.UserID, Channel: command.ChannelID}, slackClient)
-		}
-		listOfEnv, err := helper.ListEnvironments(token, orgId.(string))
-		if err != nil {
-			return err
-		}
-		var concatenatedString []string
-		for _, v := range listOfEnv.Data {
-			cacheClient.Add(v.Name, v.ID, 10*time.Hour)
-			concatenatedString = append(concatenatedString, fmt.Sprintf("Env-Name : %s\n Env-Id : %s\n Is-Production : %v", v.Name, v.ID, v.IsProduction))
-		}
-
-		slackAttachment := slack.Attachment{
-			Text:    strings.Join(concatenatedString, "\n\n"),
-			Pretext: "List Of Environemnts",
-		}
-
-		_, _, err = slackClient.PostMessage(command.ChannelID, slack.MsgOptionAttachments(slackAttachment))
-		if err != nil {
-			return err
-		}
-	case "/download-asset":
-		if !status {
-			log.Fatal("No access token is there. Please login")
-			return errors.New("Please login again")
-		}
-		fileName, err := helper.DownloadAsset(token, orgId.(string), command.Text)
-		if err != nil {
-			return err
-		}
-		slackUploadParam := slack.FileUploadParameters{
-			Channels: []string{command.ChannelID},
-			File:     fileName,
-		}
-
-		fileoutput, err := slackClient.UploadFile(slackUploadParam)
-		if err != nil {
-			log.Printf("Slack Error:- %s", err.Error())
-			return err
-		}
-
-		log.Printf("Name: %s\n, Url: %s\n", fileoutput.Name, fileoutput.URLPrivate)
-		os.Remove(fileName)
+func handleChangeStatus(req *Request) error {
+	change := statusChange{
+		ResponseContext: req.Response,
+		Status:          req.Args.Get("status"),
+		BusinessGroupID: req.Session.BusinessGroupID,
+		EnvName:         req.Environment.Name,
+		EnvID:           req.Environment.ID,
+		AppName:         req.Args.Get("application"),
+	}
 
+	// Stopping or restarting production is confirmed in a modal first. Every production change,
+	// including a start, needs a second approver when approvals are on.
+	switch {
+	case req.Environment.Production && change.Status != "start":
+		return openStatusChangeConfirmation(req, change)
+	case req.Environment.Production && approvalsEnabled(req.Command.TeamID):
+		return requestApproval(req.Ctx, req.SlackClient, req.Response, operation(req, approval.ChangeStatus, map[string]string{"status": change.Status}))
 	}
+	return changeStatus(req.Ctx, req.SlackClient, req.Anypoint, req.Session, change, "")
+}
 
-	return nil
+func handleGetAssetInfo(req *Request) error {
+	// The organization configured for the workspace is listed when the login belongs to it,
+	// unless --org asks for another business group.
+	orgId := req.Session.BusinessGroupID
+	if ws, found := workspaces.Lookup(req.Command.TeamID); found && req.Args.Flag("org") == "" && ownsGroup(req.Session, ws.Settings().OrgID) {
+		orgId = ws.Settings().OrgID
+	}
+	response, err := req.Anypoint.GetAssetInfo(req.Ctx, orgId)
+	if err != nil {
+		return err
+	}
+
+	slackAttachment := slack.Attachment{
+		Text:    response,
+		Pretext: "Asset Information",
+	}
+
+	return req.Response.Reply(req.SlackClient, slack.MsgOptionAttachments(slackAttachment))
 }
 
-func PostMessage(channelId string, slackClient *slack.Client) {
-	attachment := slack.Attachment{
-		Pretext: "Please Mention Correct Status",
-		Color:   "#36a64f",
-		Text:    "Acceptable Status are Start, Stop, Restart",
+// ownsGroup reports whether groupId is the root organization or one of the business groups of a login.
+func ownsGroup(sess *session.Session, groupId string) bool {
+	if groupId == "" {
+		return false
 	}
+	return groupId == sess.OrgID || slices.ContainsFunc(sess.BusinessGroups, func(group session.BusinessGroup) bool { return group.ID == groupId })
+}
 
-	_, timestamp, err := slackClient.PostMessage(
-		channelId,
-		slack.MsgOptionAttachments(attachment),
-	)
+func handleListEnvironments(req *Request) error {
+	listOfEnv, err := req.Anypoint.ListEnvironments(req.Ctx, req.Session.BusinessGroupID)
+	if err != nil {
+		return err
+	}
+	req.Session.SetEnvironments(listOfEnv)
+	var concatenatedString []string
+	for _, v := range listOfEnv.Data {
+		concatenatedString = append(concatenatedString, fmt.Sprintf("Env-Name : %s\n Env-Id : %s\n Is-Production : %v", v.Name, v.ID, v.IsProduction))
+	}
+
+	slackAttachment := slack.Attachment{
+		Text:    strings.Join(concatenatedString, "\n\n"),
+		Pretext: "List Of Environemnts",
+	}
 
+	err = sessions.Save(req.Session)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
 
-	log.Print("Message Sent at this time " + timestamp)
+	return req.Response.Reply(req.SlackClient, slack.MsgOptionAttachments(slackAttachment))
+}
+
+func handleDownloadAsset(req *Request) error {
+	fileName, err := req.Anypoint.DownloadAsset(req.Ctx, req.Session.BusinessGroupID, req.Args.Get("asset"))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(fileName)
+
+	slackUploadParam := slack.FileUploadParameters{
+		Channels:        []string{req.Response.ChannelID},
+		ThreadTimestamp: req.Response.ThreadTS,
+		File:            fileName,
+		Filename:        req.Args.Get("asset") + filepath.Ext(fileName),
+	}
+
+	fileoutput, err := req.SlackClient.UploadFileContext(req.Ctx, slackUploadParam)
+	if err != nil {
+		slog.ErrorContext(req.Ctx, "Could not upload asset to Slack", "err", err)
+		return err
+	}
+
+	slog.InfoContext(req.Ctx, "Asset uploaded", "name", fileoutput.Name, "url", fileoutput.URLPrivate)
+	return nil
 }
@@ -0,0 +1,227 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jchawla2804/golang-slack-event-listener/anypoint"
+	"github.com/jchawla2804/golang-slack-event-listener/environment"
+	"github.com/jchawla2804/golang-slack-event-listener/logging"
+	"github.com/jchawla2804/golang-slack-event-listener/session"
+	"github.com/slack-go/slack"
+)
+
+// Commands is the registry the slash commands of the bot register themselves in.
+var Commands = NewRegistry()
+
+// Command describes a slash command: how it is called and what handles it.
+type Command struct {
+	Name         string
+	Usage        string
+	Description  string
+	Args         []Arg
+	Flags        []Flag
+	NeedsSession bool
+	Handler      HandlerFunc
+}
+
+// Arg describes one positional argument of a command.
+// Arguments with Choices only accept one of them, compared case insensitively.
+type Arg struct {
+	Name        string
+	Description string
+	Required    bool
+	Default     string
+	Choices     []string
+	// Secret values are scrubbed from the logs.
+	Secret bool
+}
+
+// Request is everything a command handler needs to answer a slash command.
+// Session and Anypoint are only set for commands that need a session,
+// Environment only for those that also take an environment argument.
+type Request struct {
+	Ctx         context.Context
+	SlackClient *slack.Client
+	Command     slack.SlashCommand
+	Response    ResponseContext
+	Args        Args
+	Session     *session.Session
+	Anypoint    *anypoint.Client
+	Environment environment.Environment
+}
+
+// HandlerFunc answers a slash command.
+type HandlerFunc func(req *Request) error
+
+// Middleware wraps the handler of a command, e.g. to check permissions or log timings.
+type Middleware func(cmd *Command, next HandlerFunc) HandlerFunc
+
+// Registry maps slash command names to commands.
+type Registry struct {
+	commands   map[string]*Command
+	middleware []Middleware
+}
+
+func NewRegistry() *Registry {
+	return &Registry{commands: map[string]*Command{}}
+}
+
+// Register adds a command. Registering the same name twice is a programming error and panics.
+// Commands that need a session also accept --org.
+func (r *Registry) Register(cmd Command) {
+	if _, found := r.commands[cmd.Name]; found {
+		panic("command " + cmd.Name + " registered twice")
+	}
+	if cmd.NeedsSession {
+		cmd.Flags = append(cmd.Flags, OrgFlag)
+	}
+	r.commands[cmd.Name] = &cmd
+}
+
+// Use adds middleware around every command. The first middleware added runs first.
+func (r *Registry) Use(middleware ...Middleware) {
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// Lookup returns the command with the given name.
+func (r *Registry) Lookup(name string) (*Command, bool) {
+	cmd, found := r.commands[name]
+	return cmd, found
+}
+
+// List returns all commands sorted by name.
+func (r *Registry) List() []*Command {
+	var list []*Command
+	for _, cmd := range r.commands {
+		list = append(list, cmd)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Handle runs the registered command for a slash command through the middleware.
+func (r *Registry) Handle(ctx context.Context, slackClient *slack.Client, command slack.SlashCommand) error {
+	req := &Request{
+		Ctx:         ctx,
+		SlackClient: slackClient,
+		Command:     command,
+		Response:    CommandResponse(command),
+	}
+
+	cmd, found := r.Lookup(command.Command)
+	if !found {
+		return r.unknownCommand(req)
+	}
+
+	args, err := cmd.ParseArgs(command.Text)
+	if err != nil {
+		auditInvalidArgs(cmd, command, err)
+		return err
+	}
+	for _, arg := range cmd.Args {
+		if arg.Secret {
+			logging.AddSecret(args.Get(arg.Name))
+		}
+	}
+	req.Args = args
+
+	handler := cmd.Handler
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		handler = r.middleware[i](cmd, handler)
+	}
+	return handler(req)
+}
+
+func (r *Registry) unknownCommand(req *Request) error {
+	var usages []string
+	for _, cmd := range r.List() {
+		usages = append(usages, cmd.Name+": "+cmd.Description)
+	}
+
+	attachment := slack.Attachment{
+		Pretext: "Unknown command " + req.Command.Command,
+		Text:    "Available commands:\n" + strings.Join(usages, "\n"),
+	}
+	return req.Response.Ephemeral(req.SlackClient, slack.MsgOptionAttachments(attachment))
+}
+
+// RequireSession loads the session of the caller for commands that need one and gives
+// the handler an Anypoint client for it. The active session is used unless --org names another
+// organization or business group. Users without a usable session are asked to log in instead.
+func RequireSession(cmd *Command, next HandlerFunc) HandlerFunc {
+	if !cmd.NeedsSession {
+		return next
+	}
+	return func(req *Request) error {
+		command := req.Command
+		sess, err := sessionFor(command.TeamID, command.UserID, req.Args.Flag("org"))
+		var orgErr *session.OrgError
+		if errors.As(err, &orgErr) {
+			return &UsageError{Command: cmd, Reason: err.Error()}
+		}
+		if err != nil && !errors.Is(err, session.ErrNotLoggedIn) {
+			return err
+		}
+		if err != nil {
+			slog.InfoContext(req.Ctx, "No session")
+			return PromptLogin(req.SlackClient, req.Response, "You are not logged in to Anypoint Platform.")
+		}
+		if !sess.HasBusinessGroup() {
+			slog.InfoContext(req.Ctx, "No business group selected")
+			return PromptLogin(req.SlackClient, req.Response, "Please login again and choose a business group.")
+		}
+
+		_, err = sessions.AccessToken(req.Ctx, sess)
+		if errors.Is(err, session.ErrNotLoggedIn) {
+			return PromptLogin(req.SlackClient, req.Response, "Your Anypoint Platform session has expired.")
+		}
+		if err != nil {
+			return err
+		}
+
+		req.Session = sess
+		req.Anypoint = sessions.Client(sess)
+		return next(req)
+	}
+}
+
+// LogCommands logs every command and adds its name to every record logged with req.Ctx.
+func LogCommands(cmd *Command, next HandlerFunc) HandlerFunc {
+	return func(req *Request) error {
+		req.Ctx = logging.With(req.Ctx, "command", cmd.Name)
+		slog.InfoContext(req.Ctx, "Command received", "text", req.Args.Masked(), "channel", req.Command.ChannelID)
+		return next(req)
+	}
+}
+
+// TimeCommands logs how long every command took.
+func TimeCommands(cmd *Command, next HandlerFunc) HandlerFunc {
+	return func(req *Request) error {
+		start := time.Now()
+		err := next(req)
+		slog.InfoContext(req.Ctx, "Command finished", "duration", time.Since(start), "ok", err == nil)
+		return err
+	}
+}
+
+// RequirePermission builds middleware that only runs a command when allowed returns nil.
+// The error returned by allowed is reported to the user as the reason for the denial.
+func RequirePermission(allowed func(cmd *Command, req *Request) error) Middleware {
+	return func(cmd *Command, next HandlerFunc) HandlerFunc {
+		return func(req *Request) error {
+			if err := allowed(cmd, req); err != nil {
+				return err
+			}
+			return next(req)
+		}
+	}
+}
+
+func init() {
+	Commands.Use(LogCommands, AuditCommands, RequirePermission(authorizeCommand), TimeCommands, RequireSession, ResolveEnvironment, RequirePermission(authorize))
+}
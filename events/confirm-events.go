@@ -0,0 +1,191 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jchawla2804/golang-slack-event-listener/anypoint"
+	"github.com/jchawla2804/golang-slack-event-listener/approval"
+	"github.com/jchawla2804/golang-slack-event-listener/audit"
+	"github.com/jchawla2804/golang-slack-event-listener/session"
+	"github.com/slack-go/slack"
+)
+
+// ConfirmStatusChangeCallbackID identifies the submission of the status change confirmation modal.
+const ConfirmStatusChangeCallbackID = "confirm-status-change"
+
+// ConfirmStatusChangeActionID identifies the confirm button posted when the modal could not be opened.
+const ConfirmStatusChangeActionID = "confirm-status-change"
+
+// statusChange is a requested application status change. It travels in the private
+// metadata of the confirmation modal, so it also carries where to reply.
+type statusChange struct {
+	ResponseContext
+	Status          string `json:"status"`
+	BusinessGroupID string `json:"businessGroupId"`
+	EnvName         string `json:"envName"`
+	EnvID           string `json:"envId"`
+	AppName         string `json:"appName"`
+}
+
+// openStatusChangeConfirmation shows a modal with the current state of the application
+// and only changes its status once the user confirms.
+// Slack only accepts the trigger for a few seconds, so the modal is opened while the application
+// is still being looked up and filled in afterwards. When the trigger has expired anyway, e.g. after
+// a wait in the queue, the confirmation is posted as an ephemeral message with a confirm button.
+func openStatusChangeConfirmation(req *Request, change statusChange) error {
+	metadata, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+
+	modal := slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		CallbackID:      ConfirmStatusChangeCallbackID,
+		PrivateMetadata: string(metadata),
+		Title:           slack.NewTextBlockObject(slack.PlainTextType, "Confirm "+change.Status, false, false),
+		Close:           slack.NewTextBlockObject(slack.PlainTextType, "Cancel", false, false),
+		Blocks: slack.Blocks{BlockSet: []slack.Block{
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf(":hourglass_flowing_sand: Looking up %s in %s...", change.AppName, change.EnvName), false, false), nil, nil),
+		}},
+	}
+	view, err := req.SlackClient.OpenViewContext(req.Ctx, req.Command.TriggerID, modal)
+	if err != nil {
+		slog.WarnContext(req.Ctx, "Could not open confirmation modal, asking in a message instead", "err", err)
+		return postStatusChangeConfirmation(req, change, string(metadata))
+	}
+
+	blocks, err := statusChangeDetails(req, change)
+	if err != nil {
+		modal.Blocks = slack.Blocks{BlockSet: []slack.Block{
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf(":x: Could not look up %s in %s. Nothing was changed.", change.AppName, change.EnvName), false, false), nil, nil),
+		}}
+		modal.Close = slack.NewTextBlockObject(slack.PlainTextType, "Close", false, false)
+		if _, updateErr := req.SlackClient.UpdateViewContext(req.Ctx, modal, "", view.Hash, view.ID); updateErr != nil {
+			slog.WarnContext(req.Ctx, "Could not update confirmation modal", "err", updateErr)
+		}
+		return err
+	}
+
+	modal.Submit = slack.NewTextBlockObject(slack.PlainTextType, "Confirm "+change.Status, false, false)
+	modal.Blocks = slack.Blocks{BlockSet: blocks}
+	_, err = req.SlackClient.UpdateViewContext(req.Ctx, modal, "", view.Hash, view.ID)
+	return err
+}
+
+// postStatusChangeConfirmation asks for the confirmation in an ephemeral message. Its button
+// carries the same metadata as the modal and is handled by HandleStatusChangeConfirmation.
+func postStatusChangeConfirmation(req *Request, change statusChange, metadata string) error {
+	blocks, err := statusChangeDetails(req, change)
+	if err != nil {
+		return err
+	}
+
+	confirm := slack.NewButtonBlockElement(ConfirmStatusChangeActionID, metadata, slack.NewTextBlockObject(slack.PlainTextType, "Confirm "+change.Status, false, false)).WithStyle(slack.StyleDanger)
+	blocks = append(blocks, slack.NewActionBlock("", confirm))
+	return req.Response.Ephemeral(req.SlackClient, slack.MsgOptionBlocks(blocks...))
+}
+
+// statusChangeDetails looks up the application and describes the change to be confirmed.
+func statusChangeDetails(req *Request, change statusChange) ([]slack.Block, error) {
+	app, err := req.Anypoint.GetApplication(req.Ctx, change.EnvID, req.Session.BusinessGroupID, change.AppName)
+	if err != nil {
+		return nil, err
+	}
+
+	details := fmt.Sprintf("*Application:* %s\n*Environment:* %s (production)\n*Current status:* %s\n*Workers:* %d x %s",
+		change.AppName, change.EnvName, app.Status, app.Workers.Amount, app.Workers.Type.Name)
+	return []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf(":warning: You are about to *%s* an application in a production environment.", change.Status), false, false), nil, nil),
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, details, false, false), nil, nil),
+	}, nil
+}
+
+// HandleStatusChangeConfirmation runs a status change once the confirmation modal was submitted
+// or its confirm button clicked, or puts it up for approval when production changes need a second approver.
+// The user who confirmed is recorded with the change.
+func HandleStatusChangeConfirmation(ctx context.Context, slackClient *slack.Client, privateMetadata, confirmedBy string) error {
+	change := statusChange{}
+	err := json.Unmarshal([]byte(privateMetadata), &change)
+	if err != nil {
+		return fmt.Errorf("invalid confirmation: %w", err)
+	}
+	if change.UserID != confirmedBy {
+		return errors.New("only the user who requested the status change can confirm it")
+	}
+
+	sess, err := sessionFor(change.TeamID, confirmedBy, change.BusinessGroupID)
+	if errors.Is(err, session.ErrNotLoggedIn) {
+		return PromptLogin(slackClient, change.ResponseContext, "Your Anypoint Platform session has expired.")
+	}
+	if err != nil {
+		return err
+	}
+
+	if approvalsEnabled(change.TeamID) {
+		return requestApproval(ctx, slackClient, change.ResponseContext, approval.Operation{
+			Kind:              approval.ChangeStatus,
+			Params:            map[string]string{"status": change.Status},
+			TeamID:            change.TeamID,
+			BusinessGroupID:   sess.BusinessGroupID,
+			BusinessGroupName: sess.BusinessGroupName,
+			EnvName:           change.EnvName,
+			EnvID:             change.EnvID,
+			AppName:           change.AppName,
+		})
+	}
+	ctx, requestIds := anypoint.WithRequestIDs(ctx)
+	start := time.Now()
+	err = changeStatus(ctx, slackClient, sessions.Client(sess), sess, change, confirmedBy)
+
+	entry := audit.Entry{
+		TeamID:          change.TeamID,
+		UserID:          confirmedBy,
+		Action:          "confirm-status-change",
+		Text:            change.Status,
+		BusinessGroupID: sess.BusinessGroupID,
+		EnvName:         change.EnvName,
+		EnvID:           change.EnvID,
+		AppName:         change.AppName,
+		Latency:         time.Since(start),
+		RequestIDs:      requestIds.List(),
+	}
+	auditResult(&entry, err)
+	recordAudit(entry)
+	return err
+}
+
+// HandleStatusChangeButton handles the confirm button of postStatusChangeConfirmation. The message is
+// replaced first, so the button cannot be clicked a second time.
+func HandleStatusChangeButton(ctx context.Context, slackClient *slack.Client, rc ResponseContext, metadata string) error {
+	if rc.ResponseURL != "" {
+		_, _, err := slackClient.PostMessageContext(ctx, rc.UserID, slack.MsgOptionReplaceOriginal(rc.ResponseURL), slack.MsgOptionText(":hourglass_flowing_sand: Confirmed, working on it...", false))
+		if err != nil {
+			slog.WarnContext(ctx, "Could not replace confirmation message", "err", err)
+		}
+	}
+	return HandleStatusChangeConfirmation(ctx, slackClient, metadata, rc.UserID)
+}
+
+func changeStatus(ctx context.Context, slackClient *slack.Client, client *anypoint.Client, sess *session.Session, change statusChange, confirmedBy string) error {
+	_, err := client.ChangeAppStatus(ctx, change.Status, change.EnvID, sess.BusinessGroupID, change.AppName)
+	if err != nil {
+		return err
+	}
+
+	text := "Status Of API " + change.AppName + " has changed to " + change.Status
+	if confirmedBy != "" {
+		slog.InfoContext(ctx, "Status change confirmed", "app", change.AppName, "env", change.EnvName, "status", change.Status, "confirmed_by", confirmedBy)
+		text += fmt.Sprintf("\nConfirmed by <@%s>", confirmedBy)
+	}
+	slackAttachment := slack.Attachment{
+		Text:    text,
+		Pretext: "Status has changed",
+	}
+
+	return change.Reply(slackClient, slack.MsgOptionAttachments(slackAttachment))
+}
@@ -0,0 +1,81 @@
+package events
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/jchawla2804/golang-slack-event-listener/approval"
+	"github.com/slack-go/slack"
+)
+
+func init() {
+	Commands.Register(Command{
+		Name:        "/scale-app",
+		Description: "Changes the number of workers of an application",
+		Args: []Arg{
+			{Name: "environment", Description: "environment name", Required: true},
+			{Name: "application", Description: "application name", Required: true},
+			{Name: "workers", Description: "number of workers", Required: true},
+		},
+		NeedsSession: true,
+		Handler:      handleScaleApp,
+	})
+	Commands.Register(Command{
+		Name:        "/set-property",
+		Description: "Sets a property of an application",
+		Args: []Arg{
+			{Name: "environment", Description: "environment name", Required: true},
+			{Name: "application", Description: "application name", Required: true},
+			{Name: "name", Description: "property name", Required: true},
+			{Name: "value", Description: "property value, quoted if it has spaces", Required: true, Secret: true},
+		},
+		NeedsSession: true,
+		Handler:      handleSetProperty,
+	})
+}
+
+func handleScaleApp(req *Request) error {
+	workers, err := strconv.Atoi(req.Args.Get("workers"))
+	if err != nil || workers < 1 {
+		cmd, _ := Commands.Lookup(req.Command.Command)
+		return &UsageError{Command: cmd, Reason: "workers must be a positive number"}
+	}
+
+	op := operation(req, approval.Scale, map[string]string{"workers": strconv.Itoa(workers)})
+	return runOperation(req, op, fmt.Sprintf("Application %s now runs on %d workers", op.AppName, workers))
+}
+
+func handleSetProperty(req *Request) error {
+	op := operation(req, approval.SetProperty, map[string]string{"name": req.Args.Get("name"), "value": req.Args.Get("value")})
+	return runOperation(req, op, fmt.Sprintf("Property %s of application %s has been updated", op.Params["name"], op.AppName))
+}
+
+// operation builds the operation a command asks for on an application in an environment.
+func operation(req *Request, kind string, params map[string]string) approval.Operation {
+	return approval.Operation{
+		Kind:              kind,
+		Params:            params,
+		TeamID:            req.Command.TeamID,
+		BusinessGroupID:   req.Session.BusinessGroupID,
+		BusinessGroupName: req.Session.BusinessGroupName,
+		EnvName:           req.Environment.Name,
+		EnvID:             req.Environment.ID,
+		AppName:           req.Args.Get("application"),
+	}
+}
+
+// runOperation executes the operation, or puts it up for approval if it targets production.
+func runOperation(req *Request, op approval.Operation, done string) error {
+	if approvalsEnabled(req.Command.TeamID) && req.Environment.Production {
+		return requestApproval(req.Ctx, req.SlackClient, req.Response, op)
+	}
+
+	err := executeOperation(req.Ctx, req.Anypoint, op)
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(req.Ctx, "Operation finished", "operation", op.Kind, "app", op.AppName, "env", op.EnvName)
+	return req.Response.Reply(req.SlackClient, slack.MsgOptionAttachments(slack.Attachment{Pretext: "Application updated", Text: done}))
+}
@@ -0,0 +1,91 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/jchawla2804/golang-slack-event-listener/anypoint"
+	"github.com/jchawla2804/golang-slack-event-listener/environment"
+	"github.com/jchawla2804/golang-slack-event-listener/session"
+)
+
+// environmentAliases is swapped when the configuration is reloaded.
+var environmentAliases atomic.Pointer[environment.Aliases]
+
+// UseEnvironmentAliases lets users name environments by the aliases configured for their business group.
+// It may be called again while the bot is running.
+func UseEnvironmentAliases(aliases environment.Aliases) {
+	environmentAliases.Store(&aliases)
+}
+
+// ResolveEnvironment resolves the environment argument of a command to an environment of the
+// caller's business group, so handlers and the access policy see its real name and id.
+// The environments are listed from Anypoint Platform on first use.
+func ResolveEnvironment(cmd *Command, next HandlerFunc) HandlerFunc {
+	if !cmd.NeedsSession || !cmd.hasArg("environment") {
+		return next
+	}
+	return func(req *Request) error {
+		env, err := resolveEnvironment(req.Ctx, req.Anypoint, req.Session, req.Args.Get("environment"))
+		var notFound *environment.NotFoundError
+		var ambiguous *environment.AmbiguousError
+		var productionPrefix *environment.ProductionPrefixError
+		if errors.As(err, &notFound) || errors.As(err, &ambiguous) || errors.As(err, &productionPrefix) {
+			return &UsageError{Command: cmd, Reason: err.Error()}
+		}
+		if err != nil {
+			return err
+		}
+		req.Environment = env
+		return next(req)
+	}
+}
+
+func resolveEnvironment(ctx context.Context, client *anypoint.Client, sess *session.Session, name string) (environment.Environment, error) {
+	var aliases map[string]string
+	if configured := environmentAliases.Load(); configured != nil {
+		aliases = configured.For(sess.BusinessGroupID, sess.BusinessGroupName)
+	}
+
+	refreshed := false
+	if len(sess.Environments) == 0 {
+		if err := refreshEnvironments(ctx, client, sess); err != nil {
+			return environment.Environment{}, err
+		}
+		refreshed = true
+	}
+
+	envName, err := environment.Resolve(name, sess.EnvironmentNames(), aliases, sess.ProductionEnvironments)
+	var notFound *environment.NotFoundError
+	if errors.As(err, &notFound) && !refreshed {
+		// The environment may have been created since the list was cached.
+		if err := refreshEnvironments(ctx, client, sess); err != nil {
+			return environment.Environment{}, err
+		}
+		envName, err = environment.Resolve(name, sess.EnvironmentNames(), aliases, sess.ProductionEnvironments)
+	}
+	if err != nil {
+		return environment.Environment{}, err
+	}
+
+	if envName != name {
+		slog.DebugContext(ctx, "Resolved environment", "name", name, "env", envName)
+	}
+	return environment.Environment{
+		Name:       envName,
+		ID:         sess.Environments[envName],
+		Production: sess.IsProduction(envName),
+	}, nil
+}
+
+// refreshEnvironments lists the environments of the business group and caches them in the session.
+func refreshEnvironments(ctx context.Context, client *anypoint.Client, sess *session.Session) error {
+	list, err := client.ListEnvironments(ctx, sess.BusinessGroupID)
+	if err != nil {
+		return err
+	}
+	sess.SetEnvironments(list)
+	return sessions.Save(sess)
+}
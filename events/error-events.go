@@ -0,0 +1,77 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/jchawla2804/golang-slack-event-listener/anypoint"
+	"github.com/slack-go/slack"
+)
+
+// ReportError tells the user who triggered a request why it failed.
+// Anypoint API errors are rendered as a message saying what to do next.
+func ReportError(slackClient *slack.Client, rc ResponseContext, err error) error {
+	if errors.Is(err, anypoint.ErrUnauthorized) {
+		return PromptLogin(slackClient, rc, "Anypoint Platform rejected your session.")
+	}
+
+	attachment := slack.Attachment{
+		Pretext: "Request failed",
+		Color:   "#e01e5a",
+		Text:    errorText(err),
+	}
+	var usageErr *UsageError
+	var permissionErr *PermissionError
+	switch {
+	case errors.As(err, &usageErr):
+		attachment.Pretext = "Invalid arguments for " + usageErr.Command.Name
+	case errors.As(err, &permissionErr):
+		attachment.Pretext = "Permission denied"
+	}
+
+	postErr := rc.Ephemeral(slackClient, slack.MsgOptionAttachments(attachment))
+	if postErr != nil {
+		slog.Error("Error Happened While reporting error to user", "user", rc.UserID, "err", postErr)
+		return postErr
+	}
+	return nil
+}
+
+func errorText(err error) string {
+	var apiErr *anypoint.APIError
+	var usageErr *UsageError
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "Your request took too long and was stopped. Please try again."
+	}
+	if errors.As(err, &usageErr) {
+		// The usage text has <argument> placeholders and may quote what the user typed.
+		return slackEscaper.Replace(err.Error())
+	}
+	if !errors.As(err, &apiErr) {
+		return err.Error()
+	}
+
+	var text string
+	switch {
+	case errors.Is(err, anypoint.ErrUnauthorized):
+		text = "Your Anypoint Platform login is no longer valid. Please login again."
+	case errors.Is(err, anypoint.ErrForbidden):
+		text = "Your Anypoint Platform user is not allowed to do this. Ask an organization administrator for the required permission."
+	case errors.Is(err, anypoint.ErrNotFound):
+		text = "Anypoint Platform could not find what you asked for. Check the application, asset or environment name."
+	case errors.Is(err, anypoint.ErrRateLimited):
+		text = "Anypoint Platform is rate limiting requests. Please try again in a minute."
+	default:
+		text = fmt.Sprintf("Anypoint Platform answered with status %d.", apiErr.StatusCode)
+	}
+
+	if apiErr.Message != "" {
+		text += "\nMessage: " + apiErr.Message
+	}
+	if apiErr.RequestID != "" {
+		text += "\nRequest ID: " + apiErr.RequestID
+	}
+	return text
+}
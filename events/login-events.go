@@ -1,23 +1,29 @@
 package events
 
 import (
+	"context"
 	"errors"
-	"fmt"
-	"log"
-	"os"
-	"time"
+	"log/slog"
+	"strings"
 
-	"github.com/jchawla2804/golang-slack-event-listener/helper"
+	"github.com/jchawla2804/golang-slack-event-listener/anypoint"
+	"github.com/jchawla2804/golang-slack-event-listener/session"
 	"github.com/slack-go/slack"
 )
 
-func HandleInteractiveDialogBoxEvent(slackClient *slack.Client, buttonValue, triggerId string) error {
+// LoginCallbackID identifies the submission of the login modal.
+const LoginCallbackID = "login"
+
+func HandleInteractiveDialogBoxEvent(ctx context.Context, slackClient *slack.Client, rc ResponseContext, buttonValue, triggerId string) error {
 	modal := slack.ModalViewRequest{}
+	// The submission of the modal has no channel; keep the one the login started in.
+	modal.PrivateMetadata = rc.Metadata()
 	modal.Title = &slack.TextBlockObject{Type: slack.PlainTextType, Text: "Login To Platform"}
 	modal.Submit = &slack.TextBlockObject{Type: slack.PlainTextType, Text: "Submit"}
 	modal.Type = slack.VTModal
+	modal.CallbackID = LoginCallbackID
 	var blockSet []slack.Block
-	log.Println("Button Value ", buttonValue)
+	slog.DebugContext(ctx, "Opening login modal", "auth_type", buttonValue)
 	if buttonValue == "basic-auth" {
 		blockSet = []slack.Block{
 			slack.NewInputBlock("username", &slack.TextBlockObject{Type: slack.PlainTextType, Text: "Enter Username"}, nil, slack.PlainTextInputBlockElement{Type: slack.METPlainTextInput, ActionID: "user"}),
@@ -29,13 +35,12 @@ func HandleInteractiveDialogBoxEvent(slackClient *slack.Client, buttonValue, tri
 			slack.NewInputBlock("clientSecret", &slack.TextBlockObject{Type: slack.PlainTextType, Text: "Enter Connected app client secret"}, nil, slack.PlainTextInputBlockElement{Type: slack.METPlainTextInput, ActionID: "clientSecret"}),
 		}
 	}
+	blockSet = append(blockSet, controlPlaneBlock())
 	modal.Blocks = slack.Blocks{BlockSet: blockSet}
 
-	//_, timestamp, err := slackClient.PostMessage(os.Getenv("CHANNEL_ID"), slack)
-
-	_, err := slackClient.OpenView(triggerId, modal)
+	_, err := slackClient.OpenViewContext(ctx, triggerId, modal)
 	if err != nil {
-		log.Println(err.Error())
+		slog.ErrorContext(ctx, "Could not open login modal", "err", err)
 		return err
 	}
 
@@ -43,47 +48,75 @@ func HandleInteractiveDialogBoxEvent(slackClient *slack.Client, buttonValue, tri
 
 }
 
-func HandleLogin(slackClient *slack.Client, username, password, typeOfAuth string) error {
+func HandleLogin(ctx context.Context, slackClient *slack.Client, rc ResponseContext, username, password, typeOfAuth, controlPlane string) error {
+	slog.InfoContext(ctx, "Logging in to Anypoint Platform", "auth_type", typeOfAuth, "control_plane", controlPlane)
+	plane, err := anypoint.ParseControlPlane(controlPlane)
+	if err != nil {
+		return err
+	}
 
-	log.Println("Type of Auth ", typeOfAuth)
-	token, err := helper.GetToken(username, password, typeOfAuth)
+	token, err := anypoint.New(anypoint.OptionControlPlane(plane)).GetToken(ctx, username, password, typeOfAuth)
 	if err != nil {
 		slackAttachment := slack.Attachment{
-			Text:    "ERROR",
-			Pretext: "Invalid username/password",
+			Text:    errorText(err),
+			Pretext: "Unable to login",
+		}
+		if errors.Is(err, anypoint.ErrUnauthorized) {
+			slackAttachment.Text = "Invalid username/password"
 		}
-		slackClient.PostMessage(os.Getenv("CHANNEL_ID"), slack.MsgOptionAttachments(slackAttachment))
-		return errors.New("unable To login")
+		return rc.Ephemeral(slackClient, slack.MsgOptionAttachments(slackAttachment))
 	}
 
-	platformDetails, err := helper.GetPlatformInformation(token.(string))
+	sess := session.New(rc.TeamID, rc.UserID, plane, typeOfAuth, username, password, token)
+	platformDetails, err := sessions.Client(sess).GetPlatformInformation(ctx)
+	if err == nil {
+		err = sess.SetOrganizations(platformDetails)
+	}
 	if err != nil {
-		fmt.Print("error retrieving Platform information")
-		return errors.New("error Retrieving Platform information")
+		slog.ErrorContext(ctx, "Could not retrieve platform information", "err", err)
+		slackAttachment := slack.Attachment{
+			Text:    errorText(err),
+			Pretext: "Error Retrieving Platform information",
+		}
+		return rc.Ephemeral(slackClient, slack.MsgOptionAttachments(slackAttachment))
 	}
 
-	err = cacheClient.Add("access_token", token, 3600*time.Second)
+	// Logins to other organizations are kept; the new one becomes active.
+	err = sessions.Save(sess)
+	if err == nil {
+		err = sessions.Activate(sess)
+	}
 	if err != nil {
-		return errors.New("error Occured while caching token")
+		return errors.New("error Occured while saving session")
 	}
+	slog.InfoContext(ctx, "Logged in to Anypoint organization", "org", sess.OrgName)
 
 	var businessGroupOptions []*slack.OptionBlockObject
 
-	for _, v := range platformDetails.User.ContributorOfOrganizations {
-		businessGroupOptions = append(businessGroupOptions, slack.NewOptionBlockObject(v.Id, slack.NewTextBlockObject("plain_text", v.Name, false, false), nil))
+	for _, v := range sess.BusinessGroups {
+		businessGroupOptions = append(businessGroupOptions, slack.NewOptionBlockObject(v.ID, slack.NewTextBlockObject("plain_text", v.Name, false, false), nil))
 
 	}
 
 	slackSelectBlockElement := slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, slack.NewTextBlockObject("plain_text", "Choose Business Group", false, false), "select2", businessGroupOptions...)
 
-	block := slack.NewActionBlock("bg-block", slackSelectBlockElement)
+	// The block names the organization, since the user may log in to another one before choosing.
+	block := slack.NewActionBlock(BusinessGroupBlockPrefix+sess.OrgID, slackSelectBlockElement)
 
 	sectionBlock := slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", "You are logged in to platform. Choose The Business Group", false, false), nil, nil)
 
-	_, _, err = slackClient.PostMessage(os.Getenv("CHANNEL_ID"), slack.MsgOptionBlocks(sectionBlock, block))
-	if err != nil {
-		return err
+	return rc.Ephemeral(slackClient, slack.MsgOptionBlocks(sectionBlock, block))
+
+}
+
+// controlPlaneBlock lets the user pick the Anypoint control plane their organization lives in.
+func controlPlaneBlock() slack.Block {
+	var options []*slack.OptionBlockObject
+	for _, plane := range anypoint.ControlPlanes {
+		options = append(options, slack.NewOptionBlockObject(string(plane), slack.NewTextBlockObject(slack.PlainTextType, strings.ToUpper(string(plane))+" ("+plane.BaseURL()+")", false, false), nil))
 	}
-	return nil
 
+	selectElement := slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, slack.NewTextBlockObject(slack.PlainTextType, "Choose Control Plane", false, false), "plane", options...)
+	selectElement.InitialOption = options[0]
+	return slack.NewInputBlock("controlPlane", slack.NewTextBlockObject(slack.PlainTextType, "Control Plane", false, false), nil, selectElement)
 }
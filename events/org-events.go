@@ -0,0 +1,109 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jchawla2804/golang-slack-event-listener/session"
+	"github.com/slack-go/slack"
+)
+
+// OrgFlag lets every command that needs a session act on another organization or business group
+// of the user's logins, without switching to it.
+var OrgFlag = Flag{Name: "org", Description: "organization or business group to use for this command, by name or id"}
+
+func init() {
+	Commands.Register(Command{
+		Name:        "/org",
+		Description: "Lists the organizations you are logged in to, or switches to another organization or business group",
+		Args:        []Arg{{Name: "org", Description: "organization or business group to switch to, by name or id"}},
+		Handler:     handleOrg,
+	})
+}
+
+// sessionFor returns the session of a user that acts on a business group,
+// or the active session if businessGroupId is empty.
+func sessionFor(teamId, userId, businessGroupId string) (*session.Session, error) {
+	if businessGroupId == "" {
+		return sessions.Get(teamId, userId)
+	}
+	sess, group, err := sessions.Find(teamId, userId, businessGroupId)
+	if err != nil {
+		return nil, err
+	}
+	return sess.WithBusinessGroup(group), nil
+}
+
+func handleOrg(req *Request) error {
+	org := req.Args.Get("org")
+	if org == "" {
+		return listOrgs(req)
+	}
+
+	sess, group, err := sessions.Find(req.Command.TeamID, req.Command.UserID, org)
+	var orgErr *session.OrgError
+	switch {
+	case errors.Is(err, session.ErrNotLoggedIn):
+		return PromptLogin(req.SlackClient, req.Response, "You are not logged in to Anypoint Platform.")
+	case errors.As(err, &orgErr):
+		cmd, _ := Commands.Lookup(req.Command.Command)
+		return &UsageError{Command: cmd, Reason: err.Error()}
+	case err != nil:
+		return err
+	}
+
+	sess.UseBusinessGroup(group)
+	err = sessions.Save(sess)
+	if err != nil {
+		return err
+	}
+	err = sessions.Activate(sess)
+	if err != nil {
+		return err
+	}
+
+	attachment := slack.Attachment{
+		Pretext: "Organization switched",
+		Color:   "#36a64f",
+		Text:    fmt.Sprintf("Commands now act on business group %s (%s) of %s.", group.Name, group.ID, sess.OrgName),
+	}
+	return req.Response.Ephemeral(req.SlackClient, slack.MsgOptionAttachments(attachment))
+}
+
+func listOrgs(req *Request) error {
+	list, err := sessions.List(req.Command.TeamID, req.Command.UserID)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		return PromptLogin(req.SlackClient, req.Response, "You are not logged in to Anypoint Platform.")
+	}
+	active, err := sessions.Get(req.Command.TeamID, req.Command.UserID)
+	if err != nil && !errors.Is(err, session.ErrNotLoggedIn) {
+		return err
+	}
+
+	var lines []string
+	for _, sess := range list {
+		line := fmt.Sprintf("*%s* (%s, %s)", sess.OrgName, sess.OrgID, strings.ToUpper(string(sess.ControlPlane)))
+		if active != nil && active.OrgID == sess.OrgID {
+			line += " · active"
+		}
+		var groups []string
+		for _, group := range sess.BusinessGroups {
+			if group.ID == sess.BusinessGroupID {
+				groups = append(groups, "*"+group.Name+"* (selected)")
+			} else {
+				groups = append(groups, group.Name)
+			}
+		}
+		lines = append(lines, line+"\n  Business groups: "+strings.Join(groups, ", "))
+	}
+
+	attachment := slack.Attachment{
+		Pretext: "Anypoint organizations",
+		Text:    strings.Join(lines, "\n") + "\n\nSwitch with `/org <name>`, or use `--org <name>` on a single command. Mention the bot to log in to another organization.",
+	}
+	return req.Response.Ephemeral(req.SlackClient, slack.MsgOptionAttachments(attachment))
+}
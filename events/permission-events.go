@@ -0,0 +1,86 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/jchawla2804/golang-slack-event-listener/rbac"
+)
+
+var policy atomic.Pointer[rbac.Policy]
+
+// PermissionError is returned when the access policy does not allow a user to run a command.
+type PermissionError struct {
+	Command     string
+	Environment string
+}
+
+func (e *PermissionError) Error() string {
+	if e.Environment != "" {
+		return fmt.Sprintf("You are not allowed to run %s on environment %s. Ask a bot administrator for access.", e.Command, e.Environment)
+	}
+	return fmt.Sprintf("You are not allowed to run %s. Ask a bot administrator for access.", e.Command)
+}
+
+// UsePolicy enforces the access policy on every command. Without a policy every command is allowed.
+// User group memberships are looked up in the workspace of the user. It may be called again while the bot is running.
+func UsePolicy(p *rbac.Policy) {
+	policy.Store(p)
+}
+
+// authorizeCommand checks the command alone against the access policy, before anything is looked up for it.
+// Users who may not run it in any environment or business group are turned away here.
+func authorizeCommand(cmd *Command, req *Request) error {
+	return authorizeResource(cmd, req, rbac.Resource{Command: cmd.Name})
+}
+
+// authorize checks the command against the access policy for the environment and business group it acts on.
+func authorize(cmd *Command, req *Request) error {
+	if req.Environment.Name == "" && req.Session == nil {
+		// Nothing to scope the check to; authorizeCommand has already allowed it.
+		return nil
+	}
+
+	resource := rbac.Resource{
+		Command:     cmd.Name,
+		Environment: req.Environment.Name,
+		Production:  req.Environment.Production,
+	}
+	if req.Session != nil {
+		resource.BusinessGroupID = req.Session.BusinessGroupID
+		resource.BusinessGroupName = req.Session.BusinessGroupName
+	}
+	return authorizeResource(cmd, req, resource)
+}
+
+func authorizeResource(cmd *Command, req *Request, resource rbac.Resource) error {
+	rule, allowed := permitted(req.Ctx, req.Command.TeamID, req.Command.UserID, resource)
+	if allowed {
+		slog.DebugContext(req.Ctx, "Command allowed", "rule", rule, "env", resource.Environment)
+		return nil
+	}
+
+	slog.WarnContext(req.Ctx, "Command denied", "text", req.Args.Masked(), "env", resource.Environment)
+	return &PermissionError{Command: cmd.Name, Environment: resource.Environment}
+}
+
+// permitted reports whether the access policy lets a user of a team act on the resource,
+// and the name of the rule that does.
+func permitted(ctx context.Context, teamId, userId string, resource rbac.Resource) (string, bool) {
+	p := policy.Load()
+	if p == nil {
+		return "", true
+	}
+
+	subject := rbac.Subject{UserID: userId}
+	if ws, found := workspaces.Lookup(teamId); found {
+		groups, err := ws.Groups.Groups(userId)
+		if err != nil {
+			slog.WarnContext(ctx, "Could not look up user groups", "err", err)
+		}
+		subject.Groups = groups
+	}
+	return p.Allowed(subject, resource)
+}
@@ -0,0 +1,128 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// ResponseContext says where the reply to a request belongs: the channel and thread
+// it was made in, the user who made it and the response URL Slack gave for it.
+type ResponseContext struct {
+	TeamID      string `json:"teamId"`
+	ChannelID   string `json:"channelId,omitempty"`
+	ThreadTS    string `json:"threadTs,omitempty"`
+	UserID      string `json:"userId"`
+	ResponseURL string `json:"responseUrl,omitempty"`
+}
+
+// CommandResponse answers in the channel a slash command was run in.
+func CommandResponse(command slack.SlashCommand) ResponseContext {
+	return ResponseContext{
+		TeamID:      command.TeamID,
+		ChannelID:   command.ChannelID,
+		UserID:      command.UserID,
+		ResponseURL: command.ResponseURL,
+	}
+}
+
+// MentionResponse answers in a thread under the message that mentioned the bot.
+func MentionResponse(teamId string, mention *slackevents.AppMentionEvent) ResponseContext {
+	threadTS := mention.ThreadTimeStamp
+	if threadTS == "" {
+		threadTS = mention.TimeStamp
+	}
+	return ResponseContext{TeamID: teamId, ChannelID: mention.Channel, ThreadTS: threadTS, UserID: mention.User}
+}
+
+// InteractionResponse answers where a button was clicked or a menu was used.
+// Modal submissions carry no channel, so the context saved in the modal's private metadata is used.
+func InteractionResponse(callback slack.InteractionCallback) ResponseContext {
+	if callback.Type == slack.InteractionTypeViewSubmission {
+		rc := ResponseContext{}
+		if err := json.Unmarshal([]byte(callback.View.PrivateMetadata), &rc); err == nil && rc.UserID == callback.User.ID {
+			return rc
+		}
+	}
+
+	threadTS := callback.Container.ThreadTs
+	if threadTS == "" {
+		threadTS = callback.Message.ThreadTimestamp
+	}
+	return ResponseContext{
+		TeamID:      callback.Team.ID,
+		ChannelID:   callback.Channel.ID,
+		ThreadTS:    threadTS,
+		UserID:      callback.User.ID,
+		ResponseURL: callback.ResponseURL,
+	}
+}
+
+// Metadata encodes the context so it can travel in a modal's private metadata.
+func (rc ResponseContext) Metadata() string {
+	metadata, _ := json.Marshal(rc)
+	return string(metadata)
+}
+
+// Reply posts a message everyone in the channel can see, in the request's thread if it has one.
+// Requests without a channel are answered in the user's direct messages.
+// Where the bot is not a member, such as private channels and direct messages between users,
+// the response URL is used instead.
+func (rc ResponseContext) Reply(slackClient *slack.Client, options ...slack.MsgOption) error {
+	channelId := rc.ChannelID
+	if channelId == "" {
+		channelId = rc.UserID
+	}
+	_, timestamp, err := slackClient.PostMessage(channelId, rc.inThread(options)...)
+	if notMember(err) && rc.ResponseURL != "" {
+		slog.Debug("Bot cannot post in channel, replying through the response URL", "channel", channelId, "err", err)
+		options = append(options, slack.MsgOptionResponseURL(rc.ResponseURL, slack.ResponseTypeInChannel))
+		_, _, err = slackClient.PostMessage(rc.UserID, options...)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	slog.Debug("Message sent", "channel", channelId, "ts", timestamp)
+	return nil
+}
+
+// Ephemeral posts a message only the requesting user can see.
+// Without a channel, or in a channel the bot is not a member of, it uses the response URL,
+// and without one it falls back to the user's direct messages.
+func (rc ResponseContext) Ephemeral(slackClient *slack.Client, options ...slack.MsgOption) error {
+	if rc.ChannelID != "" {
+		_, err := slackClient.PostEphemeral(rc.ChannelID, rc.UserID, rc.inThread(options)...)
+		if !notMember(err) || rc.ResponseURL == "" {
+			return err
+		}
+		slog.Debug("Bot cannot post in channel, answering through the response URL", "channel", rc.ChannelID, "err", err)
+	}
+
+	if rc.ResponseURL != "" {
+		options = append(options, slack.MsgOptionResponseURL(rc.ResponseURL, slack.ResponseTypeEphemeral))
+	}
+	_, _, err := slackClient.PostMessage(rc.UserID, options...)
+	return err
+}
+
+// inThread adds the request's thread to a copy of options. The response URL has no thread of its own.
+func (rc ResponseContext) inThread(options []slack.MsgOption) []slack.MsgOption {
+	if rc.ThreadTS == "" {
+		return options
+	}
+	return append(slices.Clip(options), slack.MsgOptionTS(rc.ThreadTS))
+}
+
+// notMember reports whether Slack refused a message because the bot cannot post in the channel.
+func notMember(err error) bool {
+	var slackErr slack.SlackErrorResponse
+	if !errors.As(err, &slackErr) {
+		return false
+	}
+	return slackErr.Err == "channel_not_found" || slackErr.Err == "not_in_channel"
+}
@@ -1,17 +1,17 @@
 package events
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"strings"
 
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
 )
 
-func HandleSlackEventMessage(event slackevents.EventsAPIEvent, slackClient *slack.Client) error {
+func HandleSlackEventMessage(ctx context.Context, event slackevents.EventsAPIEvent, slackClient *slack.Client) error {
 	switch event.Type {
 	case slackevents.CallbackEvent:
 		innerEvent := event.InnerEvent
@@ -19,8 +19,8 @@ func HandleSlackEventMessage(event slackevents.EventsAPIEvent, slackClient *slac
 		switch ev := innerEvent.Data.(type) {
 
 		case *slackevents.AppMentionEvent:
-			log.Println("Bot Id ", ev.BotID)
-			err := HandleSlackAppMentions(ev, slackClient)
+			slog.DebugContext(ctx, "App mentioned", "bot", ev.BotID)
+			err := HandleSlackAppMentions(ctx, MentionResponse(event.TeamID, ev), ev, slackClient)
 			if err != nil {
 				return err
 			}
@@ -31,26 +31,16 @@ func HandleSlackEventMessage(event slackevents.EventsAPIEvent, slackClient *slac
 	return nil
 }
 
-func HandleSlackAppMentions(appMentionEvent *slackevents.AppMentionEvent, slackClient *slack.Client) error {
+func HandleSlackAppMentions(ctx context.Context, rc ResponseContext, appMentionEvent *slackevents.AppMentionEvent, slackClient *slack.Client) error {
 	slackUser, err := slackClient.GetUserInfo(appMentionEvent.User)
 	if err != nil {
-
-		log.Printf("Err 1. Line 179 : %s", err.Error())
+		slog.ErrorContext(ctx, "Could not look up Slack user", "err", err)
 		return err
 	}
 
 	text := strings.ToLower(appMentionEvent.Text)
 	slackAttachment := slack.Attachment{}
 
-	buttonBlockElement1 := slack.NewButtonBlockElement("button1", "basic-auth", &slack.TextBlockObject{Type: slack.PlainTextType, Text: "Login using Basic Auth"})
-	buttonBlockElement2 := slack.NewButtonBlockElement("button2", "connected-app", &slack.TextBlockObject{Type: slack.PlainTextType, Text: "Login using Connected App"})
-
-	blockSet := []slack.Block{
-		//sectionObject,
-		slack.NewSectionBlock(&slack.TextBlockObject{Type: slack.MarkdownType, Text: "Please Choose login option"}, nil, nil),
-		slack.NewActionBlock("actionblock789", buttonBlockElement1, buttonBlockElement2),
-	}
-
 	if strings.Contains(text, "hello") {
 		slackAttachment.Text = "Welcome To MuleSoft slack bot "
 		slackAttachment.Color = "#4af030"
@@ -62,14 +52,35 @@ func HandleSlackAppMentions(appMentionEvent *slackevents.AppMentionEvent, slackC
 		//slackAttachment.Color = "#3d3d3d"
 	}
 
-	_, timestamp, err := slackClient.PostMessage(os.Getenv("CHANNEL_ID"), slack.MsgOptionBlocks(blockSet...))
+	err = rc.Reply(slackClient, slack.MsgOptionAttachments(slackAttachment))
+	if err != nil {
+		slog.ErrorContext(ctx, "Error Happened While sending message", "err", err)
+		return err
+	}
 
+	// Login options are only shown to the user who mentioned the bot.
+	return PromptLogin(slackClient, rc, "")
+
+}
+
+// PromptLogin tells a Slack user who has no usable session to log in.
+// The login options are sent as an ephemeral message so only that user sees them.
+func PromptLogin(slackClient *slack.Client, rc ResponseContext, reason string) error {
+	text := strings.TrimSpace(reason + " Please Choose login option")
+	err := rc.Ephemeral(slackClient, slack.MsgOptionBlocks(loginBlocks(text)...))
 	if err != nil {
-		log.Println("Error Happened While sending message")
-		log.Print(err.Error())
+		slog.Error("Error Happened While sending login prompt", "user", rc.UserID, "err", err)
 		return err
 	}
-	fmt.Printf("Message Was Sent at this time %s", timestamp)
 	return nil
+}
 
+func loginBlocks(text string) []slack.Block {
+	buttonBlockElement1 := slack.NewButtonBlockElement("button1", "basic-auth", &slack.TextBlockObject{Type: slack.PlainTextType, Text: "Login using Basic Auth"})
+	buttonBlockElement2 := slack.NewButtonBlockElement("button2", "connected-app", &slack.TextBlockObject{Type: slack.PlainTextType, Text: "Login using Connected App"})
+
+	return []slack.Block{
+		slack.NewSectionBlock(&slack.TextBlockObject{Type: slack.MarkdownType, Text: text}, nil, nil),
+		slack.NewActionBlock("actionblock789", buttonBlockElement1, buttonBlockElement2),
+	}
 }
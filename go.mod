@@ -1,12 +1,25 @@
 module github.com/jchawla2804/golang-slack-event-listener
 
-go 1.18
+go 1.23
 
 require (
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/joho/godotenv v1.4.0
 	github.com/patrickmn/go-cache v2.1.0+incompatible
+	github.com/prometheus/client_golang v1.19.1
 	github.com/slack-go/slack v0.11.0
-	golang.org/x/exp v0.0.0-20220706164943-b4a6d9510983
+	go.etcd.io/bbolt v1.4.3
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require github.com/gorilla/websocket v1.4.2 // indirect
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/gorilla/websocket v1.4.2 // indirect
+	github.com/kr/text v0.2.0 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
+)
@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// level is the level of the default logger set up with Setup. It can be changed with SetLevel.
+var level = new(slog.LevelVar)
+
+// ParseLevel reads a level name such as debug, info, warn or error. An empty name is info.
+func ParseLevel(name string) (slog.Level, error) {
+	var lvl slog.Level
+	if name == "" {
+		return slog.LevelInfo, nil
+	}
+	err := lvl.UnmarshalText([]byte(name))
+	if err != nil {
+		return lvl, fmt.Errorf("invalid log level %q", name)
+	}
+	return lvl, nil
+}
+
+// New creates a logger writing to w in the given format, text or json.
+// Every record carries the fields added to its context with With,
+// and secrets are scrubbed from the message and all attributes.
+func New(w io.Writer, minLevel slog.Leveler, format string) (*slog.Logger, error) {
+	options := &slog.HandlerOptions{Level: minLevel}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		handler = slog.NewTextHandler(w, options)
+	case "json":
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return nil, fmt.Errorf("invalid log format %q", format)
+	}
+	return slog.New(&contextHandler{next: &redactHandler{next: handler}}), nil
+}
+
+// Setup makes a logger created with New the default logger, for slog as well as
+// for the standard log package used by libraries.
+func Setup(w io.Writer, levelName, format string) error {
+	err := SetLevel(levelName)
+	if err != nil {
+		return err
+	}
+	logger, err := New(w, level, format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	log.SetFlags(0)
+	return nil
+}
+
+// SetLevel changes the level of the default logger while the bot is running.
+func SetLevel(name string) error {
+	lvl, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	level.Set(lvl)
+	return nil
+}
+
+type contextKey struct{}
+
+// With returns a copy of ctx that adds the given fields to every record logged with it,
+// e.g. logging.With(ctx, "user", userId).
+func With(ctx context.Context, args ...any) context.Context {
+	attrs := append([]slog.Attr{}, fields(ctx)...)
+	record := slog.Record{}
+	record.Add(args...)
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	return context.WithValue(ctx, contextKey{}, attrs)
+}
+
+func fields(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	return attrs
+}
+
+// contextHandler adds the fields stored in the context of a record.
+type contextHandler struct {
+	next slog.Handler
+}
+
+func (h *contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs := fields(ctx); len(attrs) > 0 {
+		record = record.Clone()
+		record.AddAttrs(attrs...)
+	}
+	return h.next.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{next: h.next.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{next: h.next.WithGroup(name)}
+}
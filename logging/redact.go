@@ -0,0 +1,137 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces every secret scrubbed from a log record.
+const Redacted = "[REDACTED]"
+
+// maxSecrets bounds how many values registered with AddSecret are remembered.
+const maxSecrets = 1024
+
+// sensitiveKeys are attribute key fragments whose values are never logged.
+var sensitiveKeys = []string{"token", "secret", "password", "passwd", "authorization", "credential", "cookie"}
+
+// secretPatterns find secrets inside free text such as URLs, headers and response bodies.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)(bearer\s+)[^\s"',;]+`),
+	regexp.MustCompile(`(?i)((?:access_token|refresh_token|client_secret|password|token|secret)"?\s*[:=]\s*"?)[^\s"&,;}]+`),
+	regexp.MustCompile(`\b(?:xox[abpors]|xapp)-[A-Za-z0-9-]+`),
+}
+
+var secrets = struct {
+	sync.RWMutex
+	values []string
+	seen   map[string]bool
+}{seen: map[string]bool{}}
+
+// AddSecret makes the redactor scrub value wherever it appears, e.g. the value of a secure property.
+// Only the most recent secrets are remembered.
+func AddSecret(value string) {
+	if len(value) < 4 {
+		return
+	}
+	secrets.Lock()
+	defer secrets.Unlock()
+	if secrets.seen[value] {
+		return
+	}
+	if len(secrets.values) == maxSecrets {
+		delete(secrets.seen, secrets.values[0])
+		secrets.values = secrets.values[1:]
+	}
+	secrets.values = append(secrets.values, value)
+	secrets.seen[value] = true
+}
+
+// Redact scrubs tokens, passwords, client secrets and registered secrets from s.
+func Redact(s string) string {
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllStringFunc(s, func(match string) string {
+			groups := pattern.FindStringSubmatch(match)
+			if len(groups) > 1 {
+				return groups[1] + Redacted
+			}
+			return Redacted
+		})
+	}
+
+	secrets.RLock()
+	defer secrets.RUnlock()
+	for _, value := range secrets.values {
+		if strings.Contains(s, value) {
+			s = strings.ReplaceAll(s, value, Redacted)
+		}
+	}
+	return s
+}
+
+func sensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, fragment := range sensitiveKeys {
+		if strings.Contains(key, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactAttr(attr slog.Attr) slog.Attr {
+	attr.Value = attr.Value.Resolve()
+	if sensitiveKey(attr.Key) && attr.Value.Kind() != slog.KindGroup {
+		return slog.String(attr.Key, Redacted)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, Redact(attr.Value.String()))
+	case slog.KindGroup:
+		group := attr.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, member := range group {
+			redacted[i] = redactAttr(member)
+		}
+		return slog.Attr{Key: attr.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok {
+			return slog.String(attr.Key, Redact(err.Error()))
+		}
+		return slog.String(attr.Key, Redact(attr.Value.String()))
+	}
+	return attr
+}
+
+// redactHandler scrubs secrets from the message and attributes of every record.
+type redactHandler struct {
+	next slog.Handler
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, Redact(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = redactAttr(attr)
+	}
+	return &redactHandler{next: h.next.WithAttrs(redacted)}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: h.next.WithGroup(name)}
+}
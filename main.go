@@ -2,10 +2,28 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/jchawla2804/golang-slack-event-listener/audit"
+	"github.com/jchawla2804/golang-slack-event-listener/config"
+	"github.com/jchawla2804/golang-slack-event-listener/database"
+	"github.com/jchawla2804/golang-slack-event-listener/dispatcher"
+	"github.com/jchawla2804/golang-slack-event-listener/environment"
 	"github.com/jchawla2804/golang-slack-event-listener/events"
+	"github.com/jchawla2804/golang-slack-event-listener/logging"
+	"github.com/jchawla2804/golang-slack-event-listener/metrics"
+	"github.com/jchawla2804/golang-slack-event-listener/rbac"
+	"github.com/jchawla2804/golang-slack-event-listener/webhook"
+	"github.com/jchawla2804/golang-slack-event-listener/workspace"
 	"github.com/joho/godotenv"
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
@@ -13,120 +31,329 @@ import (
 )
 
 func main() {
+	rotateKeys := flag.Bool("rotate-keys", false, "re-encrypt all stored records with the primary master key and exit")
+	dumpConfig := flag.Bool("dump-config", false, "print the effective configuration with secrets masked and exit")
+	configFile := flag.String("config", "", "path of the YAML config file, CONFIG_FILE if not set")
+	flag.Parse()
+
+	// A .env file is optional; deployments may set real environment variables instead.
 	err := godotenv.Load(".env")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fatal("Error Loading Property file", err)
+	}
+
+	if *configFile == "" {
+		*configFile = os.Getenv("CONFIG_FILE")
+	}
+	cfg, err := config.Load(*configFile)
+	if *dumpConfig && cfg != nil {
+		dump, dumpErr := cfg.Dump()
+		if dumpErr != nil {
+			fatal("Could not print configuration", dumpErr)
+		}
+		os.Stdout.Write(dump)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *dumpConfig {
+		return
+	}
+
+	err = logging.Setup(os.Stderr, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		fatal("Invalid logging configuration", err)
+	}
+
+	store, err := database.NewStore(cfg.Store.Type, cfg.Store.Path)
+	if err != nil {
+		fatal("Error Opening State Store", err)
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			slog.Error("Could not close state store", "err", err)
+		}
+	}()
+
+	keyring, err := database.LoadKeyring(cfg.Store.MasterKeyFile, cfg.Store.MasterKey)
+	if errors.Is(err, database.ErrNoMasterKey) && cfg.Store.Type != "bolt" {
+		keyring, err = database.EphemeralKeyring()
+	}
+	if err != nil {
+		fatal("Error Loading Master Key", err)
+	}
+
+	encryptedStore := database.NewEncryptedStore(store, keyring)
+	err = encryptedStore.Verify()
+	if err != nil {
+		fatal("Refusing to start with unreadable state store", err)
+	}
+
+	if *rotateKeys {
+		rotated, err := encryptedStore.Rotate()
+		if err != nil {
+			fatal("Key rotation failed", err)
+		}
+		slog.Info("Re-encrypted records with the primary master key", "records", rotated)
+		return
+	}
+	events.UseStore(encryptedStore)
+
+	auditLog, err := audit.Open(cfg.Audit.File, encryptedStore, cfg.Audit.Retention)
+	if err != nil {
+		fatal("Error Opening Audit Log", err)
+	}
+	defer func() {
+		if err := auditLog.Close(); err != nil {
+			slog.Error("Could not flush audit log", "err", err)
+		}
+	}()
+	events.UseAuditLog(auditLog)
+
+	Context, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var connected []*workspace.Workspace
+	for _, wsConfig := range cfg.EffectiveWorkspaces() {
+		ws, err := workspace.Connect(Context, wsConfig)
+		if err != nil {
+			fatal("Error Connecting Workspace", err)
+		}
+		slog.Info("Workspace configured", "workspace", ws.Name, "team", ws.TeamID)
+		connected = append(connected, ws)
+	}
+	workspaces, err := workspace.NewRegistry(connected...)
+	if err != nil {
+		fatal("Invalid workspaces", err)
+	}
+	events.UseWorkspaces(workspaces)
+
+	// applyConfig puts the settings that can change while running into effect,
+	// at startup and whenever the configuration is reloaded.
+	// It reports the access rules that changed, since they are not settings of the configuration.
+	var currentPolicy *rbac.Policy
+	applyConfig := func(_, next *config.Config) ([]config.Change, error) {
+		var policy *rbac.Policy
+		if next.RBAC.PolicyFile != "" {
+			loaded, err := rbac.Load(next.RBAC.PolicyFile)
+			if err != nil {
+				return nil, fmt.Errorf("could not load access policy: %w", err)
+			}
+			policy = loaded
+		}
+		err := logging.SetLevel(next.Log.Level)
+		if err != nil {
+			return nil, err
+		}
+		events.UsePolicy(policy)
+		events.ConfigureApprovals(next.Approvals.Window, next.Approvals.Required)
+		events.ConfigureSessions(next.Anypoint.ConnectedAppLifetime)
+		events.UseEnvironmentAliases(environment.Aliases(next.Environments.Aliases))
+		workspaces.Configure(next.EffectiveWorkspaces())
+
+		var changes []config.Change
+		for _, rule := range rbac.Diff(currentPolicy, policy) {
+			changes = append(changes, config.Change{Setting: "rbac rule " + rule.Name, Old: rule.Old, New: rule.New})
+		}
+		currentPolicy = policy
+		return changes, nil
+	}
+	_, err = applyConfig(nil, cfg)
 	if err != nil {
-		log.Fatal("Error Loading Property file")
+		fatal("Error Applying Configuration", err)
 	}
 
-	slackClient := slack.New(
-		os.Getenv("SLACK_BOT_TOKEN"),
-		//slack.OptionDebug(true),
-		slack.OptionAppLevelToken(os.Getenv("SLACK_APP_TOKEN")),
+	// The config watcher and the approval expiry use the store, so they are stopped and
+	// waited for before it is closed.
+	backgroundCtx, stopBackground := context.WithCancel(Context)
+	defer stopBackground()
+	var background sync.WaitGroup
+
+	watcher := config.NewWatcher(*configFile, cfg, applyConfig, func(trigger string, changes []config.Change, err error) {
+		events.NotifyConfigReload(Context, trigger, changes, err)
+	})
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		if err := watcher.Run(backgroundCtx); err != nil {
+			slog.Error("Configuration reloading is off", "err", err)
+		}
+	}()
+
+	eventDispatcher := dispatcher.New(workspaces,
+		dispatcher.OptionWorkers(cfg.Dispatcher.Workers),
+		dispatcher.OptionQueueSize(cfg.Dispatcher.QueueSize),
+		dispatcher.OptionMaxPending(cfg.Dispatcher.MaxPending),
+		dispatcher.OptionHandlerTimeout(cfg.Dispatcher.HandlerTimeout),
 	)
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		events.WatchApprovals(backgroundCtx, time.Minute)
+	}()
+
+	connections := []string{"http"}
+	if cfg.Slack.Mode != "http" {
+		connections = nil
+		for _, ws := range workspaces.List() {
+			connections = append(connections, ws.Name)
+		}
+	}
+	health := metrics.NewHealth(2*time.Minute, connections...)
+	metrics.ActiveSessions(func() float64 {
+		count, err := events.ActiveSessions()
+		if err != nil {
+			slog.Error("Could not count sessions", "err", err)
+		}
+		return float64(count)
+	})
+	var metricsServer *metrics.Server
+	if cfg.Metrics.Addr != "" {
+		metricsServer = metrics.NewServer(cfg.Metrics.Addr, health)
+		metricsServer.Start()
+	}
+
+	if cfg.Slack.Mode == "http" {
+		err = runHTTPMode(Context, cfg.Slack.HTTPAddr, workspaces, eventDispatcher, health, cfg.ShutdownTimeout)
+	} else {
+		err = runSocketMode(Context, workspaces, eventDispatcher, health)
+	}
+	if err != nil {
+		slog.Error("Slack connection failed", "mode", cfg.Slack.Mode, "err", err)
+	}
+	stop()
+
+	// Stop taking new events and give running handlers, such as uploads and
+	// status changes, until the deadline to finish before the store is closed.
+	slog.Info("Shutting down, waiting for running requests", "timeout", cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
 
+	err = eventDispatcher.Shutdown(shutdownCtx)
+	if err != nil {
+		slog.Warn("Requests still running at the shutdown deadline were cancelled", "err", err)
+	}
+	stopBackground()
+	background.Wait()
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Could not stop metrics server", "err", err)
+		}
+	}
+	slog.Info("Listener stopped")
+}
+
+// runSocketMode receives events over one Socket Mode connection per workspace until ctx is done.
+// A workspace whose connection fails does not stop the others.
+func runSocketMode(ctx context.Context, workspaces *workspace.Registry, eventDispatcher *dispatcher.Dispatcher, health *metrics.Health) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(workspaces.List()))
+	for i, ws := range workspaces.List() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := runSocketConnection(ctx, ws, eventDispatcher, health)
+			if err != nil {
+				slog.Error("Socket Mode connection failed", "workspace", ws.Name, "err", err)
+				errs[i] = fmt.Errorf("workspace %s: %w", ws.Name, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// runSocketConnection receives the events of a workspace over a Socket Mode connection until ctx is done.
+func runSocketConnection(ctx context.Context, ws *workspace.Workspace, eventDispatcher *dispatcher.Dispatcher, health *metrics.Health) error {
 	socketClient := socketmode.New(
-		slackClient,
+		ws.Client,
 		//socketmode.OptionDebug(true),
 		//socketmode.OptionLog(log.New(os.Stdout, "socketmode: ", log.Lshortfile|log.LstdFlags)),
 	)
 
-	log.Println("Connectivity successful")
-
-	Context, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	slog.Info("Connectivity successful", "workspace", ws.Name)
 
-	go func(ctx context.Context, client *slack.Client, socketClient *socketmode.Client) {
+	go func(ctx context.Context, socketClient *socketmode.Client) {
 		for {
 			select {
 			case <-ctx.Done():
-				log.Println("Shutting Down listener")
+				slog.Info("Shutting Down listener", "workspace", ws.Name)
 				return
 
 			case event := <-socketClient.Events:
+				eventCtx := ctx
+				if event.Request != nil {
+					eventCtx = logging.With(ctx, "event_id", event.Request.EnvelopeID)
+				}
+				slog.DebugContext(eventCtx, "Socket mode event", "type", event.Type)
+				metrics.SocketEvent(string(event.Type))
+
 				switch event.Type {
+				case socketmode.EventTypeConnected:
+					health.SetConnected(ws.Name, true)
+
+				case socketmode.EventTypeConnecting, socketmode.EventTypeConnectionError, socketmode.EventTypeDisconnect, socketmode.EventTypeInvalidAuth:
+					health.SetConnected(ws.Name, false)
+
 				case socketmode.EventTypeEventsAPI:
-					log.Println("Slack api event")
 					eventApiEvent, ok := event.Data.(slackevents.EventsAPIEvent)
 					if !ok {
-						log.Printf("Could Not typecast the event : %v\n", event)
+						slog.Warn("Could Not typecast the event", "type", event.Type)
 						continue
 					}
 
 					socketClient.Ack(*event.Request)
-					err = events.HandleSlackEventMessage(eventApiEvent, client)
-					if err != nil {
-						log.Fatal(err)
-					}
+					eventDispatcher.HandleEventsAPI(eventCtx, eventApiEvent)
 
 				case socketmode.EventTypeSlashCommand:
 					slackCommandEvent, ok := event.Data.(slack.SlashCommand)
 					if !ok {
-						log.Printf("Could Not typecast the event %v\n", slackCommandEvent)
+						slog.Warn("Could Not typecast the event", "type", event.Type)
 						continue
 					}
 
 					socketClient.Ack(*event.Request)
-					err := events.HandleSlackCommands(slackClient, slackCommandEvent)
-					if err != nil {
-						log.Fatal(err)
-					}
+					eventDispatcher.HandleSlashCommand(eventCtx, slackCommandEvent)
+
 				case socketmode.EventTypeInteractive:
 					callbackEvent, ok := event.Data.(slack.InteractionCallback)
-					log.Println("Message Recieved")
 					if !ok {
-						log.Fatal("Cound not typecast event")
-					}
-
-					switch callbackEvent.Type {
-
-					// case for block actions
-					case slack.InteractionTypeBlockActions:
-						socketClient.Ack(*event.Request)
-
-						actiontype := callbackEvent.ActionCallback.BlockActions[0].Type
-
-						switch actiontype {
-						case slack.ActionType(slack.OptTypeStatic):
-							err := events.HandlePlatformInformation(slackClient, callbackEvent.ActionCallback.BlockActions[0].SelectedOption.Text.Text, callbackEvent.ActionCallback.BlockActions[0].SelectedOption.Value)
-							if err != nil {
-								log.Fatal(err.Error())
-							}
-
-						default:
-							err = events.HandleInteractiveDialogBoxEvent(slackClient, callbackEvent.ActionCallback.BlockActions[0].Value, callbackEvent.TriggerID)
-							if err != nil {
-								log.Fatal(err.Error())
-							}
-
-						}
-
-					// case Submission events
-					case slack.InteractionTypeViewSubmission:
-						socketClient.Ack(*event.Request)
-						var username, password, typeOfAuth string
-
-						if callbackEvent.View.State.Values["username"]["user"].Value == "" {
-							username = callbackEvent.View.State.Values["clientId"]["clientId"].Value
-							password = callbackEvent.View.State.Values["clientSecret"]["clientSecret"].Value
-							typeOfAuth = "oauth"
-						} else {
-							username = callbackEvent.View.State.Values["username"]["user"].Value
-							password = callbackEvent.View.State.Values["password"]["pass"].Value
-							typeOfAuth = "basic-auth"
-						}
-
-						err = events.HandleLogin(slackClient, username, password, typeOfAuth)
-						if err != nil {
-							log.Println(err.Error())
-						}
+						slog.Warn("Could Not typecast the event", "type", event.Type)
+						continue
 					}
 
+					socketClient.Ack(*event.Request)
+					eventDispatcher.HandleInteraction(eventCtx, callbackEvent)
 				}
 
 			}
 		}
-	}(Context, slackClient, socketClient)
+	}(ctx, socketClient)
+
+	err := socketClient.RunContext(ctx)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
 
-	socketClient.Run()
+// runHTTPMode receives Events API callbacks, slash commands and interactions of every workspace
+// over HTTP until ctx is done.
+func runHTTPMode(ctx context.Context, addr string, workspaces *workspace.Registry, eventDispatcher *dispatcher.Dispatcher, health *metrics.Health, shutdownTimeout time.Duration) error {
+	signingSecrets := map[string]string{}
+	for _, ws := range workspaces.List() {
+		signingSecrets[ws.TeamID] = ws.SigningSecret
+	}
+	server := webhook.NewServer(addr, signingSecrets, eventDispatcher)
+
+	health.SetConnected("http", true)
+	defer health.SetConnected("http", false)
+	return server.Serve(ctx, shutdownTimeout)
+}
 
+// fatal logs err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
 }
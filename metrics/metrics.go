@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Outcomes of a slash command.
+const (
+	OutcomeOK         = "ok"
+	OutcomeError      = "error"
+	OutcomeUsageError = "usage_error"
+	OutcomeDenied     = "denied"
+)
+
+var (
+	socketEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "slackbot_socket_events_total",
+		Help: "Socket Mode events received, by event type.",
+	}, []string{"type"})
+
+	httpEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "slackbot_http_events_total",
+		Help: "Requests received from Slack in HTTP mode, by event type.",
+	}, []string{"type"})
+
+	commands = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "slackbot_commands_total",
+		Help: "Slash commands handled, by command and outcome.",
+	}, []string{"command", "outcome"})
+
+	commandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "slackbot_command_duration_seconds",
+		Help:    "Time taken to handle a slash command, by command.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"command"})
+
+	anypointCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "slackbot_anypoint_requests_total",
+		Help: "Anypoint Platform API calls, by endpoint, method and status code.",
+	}, []string{"endpoint", "method", "code"})
+
+	anypointDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "slackbot_anypoint_request_duration_seconds",
+		Help:    "Time taken by Anypoint Platform API calls including retries, by endpoint and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint", "method"})
+
+	// Registry holds the metrics of the bot. It is served on /metrics.
+	Registry = prometheus.NewRegistry()
+)
+
+func init() {
+	Registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		socketEvents, httpEvents, commands, commandDuration, anypointCalls, anypointDuration,
+	)
+}
+
+// SocketEvent counts a Socket Mode event of the given type.
+func SocketEvent(eventType string) {
+	socketEvents.WithLabelValues(eventType).Inc()
+}
+
+// HTTPEvent counts a request of the given type received from Slack in HTTP mode.
+func HTTPEvent(eventType string) {
+	httpEvents.WithLabelValues(eventType).Inc()
+}
+
+// Command records a slash command with its outcome and how long it took.
+func Command(name, outcome string, duration time.Duration) {
+	commands.WithLabelValues(name, outcome).Inc()
+	commandDuration.WithLabelValues(name).Observe(duration.Seconds())
+}
+
+// AnypointCall records a call to the Anypoint Platform API. A status code of 0 means no response was received.
+func AnypointCall(method, path string, statusCode int, duration time.Duration) {
+	code := "error"
+	if statusCode > 0 {
+		code = strconv.Itoa(statusCode)
+	}
+	endpoint := Endpoint(path)
+	anypointCalls.WithLabelValues(endpoint, method, code).Inc()
+	anypointDuration.WithLabelValues(endpoint, method).Observe(duration.Seconds())
+}
+
+// ActiveSessions reports the number of logged in users, as counted by count on every scrape.
+func ActiveSessions(count func() float64) {
+	Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "slackbot_active_sessions",
+		Help: "Slack users with an Anypoint Platform session.",
+	}, count))
+}
+
+// endpoints turn API paths into endpoint labels without application, organization or asset names.
+var endpoints = []struct {
+	pattern  *regexp.Regexp
+	endpoint string
+}{
+	{regexp.MustCompile(`^cloudhub/api/applications/[^/]+/status$`), "cloudhub/api/applications/{app}/status"},
+	{regexp.MustCompile(`^cloudhub/api/applications/[^/]+$`), "cloudhub/api/applications/{app}"},
+	{regexp.MustCompile(`^accounts/api/organizations/[^/]+/environments$`), "accounts/api/organizations/{org}/environments"},
+	{regexp.MustCompile(`^exchange/api/v1/assets/.+$`), "exchange/api/v1/assets/{org}/{asset}"},
+}
+
+// Endpoint returns the endpoint label of an Anypoint API path.
+func Endpoint(path string) string {
+	path = strings.Trim(path, "/")
+	for _, e := range endpoints {
+		if e.pattern.MatchString(path) {
+			return e.endpoint
+		}
+	}
+	return path
+}
@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Health tracks whether the listener is connected to Slack for the health endpoints.
+// With several workspaces every connection is tracked by name.
+type Health struct {
+	mu          sync.Mutex
+	connections map[string]*connection
+	gracePeriod time.Duration
+}
+
+type connection struct {
+	connected bool
+	changedAt time.Time
+}
+
+// NewHealth creates a health tracker for the named connections. The listener counts as unhealthy once
+// a connection has been down for longer than gracePeriod, including the time it takes to connect at startup.
+func NewHealth(gracePeriod time.Duration, connections ...string) *Health {
+	h := &Health{connections: map[string]*connection{}, gracePeriod: gracePeriod}
+	for _, name := range connections {
+		h.connections[name] = &connection{changedAt: time.Now()}
+	}
+	return h
+}
+
+// SetConnected records that the named connection to Slack was established or lost.
+func (h *Health) SetConnected(name string, connected bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c, found := h.connections[name]
+	if !found {
+		c = &connection{changedAt: time.Now()}
+		h.connections[name] = c
+	}
+	if c.connected == connected {
+		return
+	}
+	c.connected = connected
+	c.changedAt = time.Now()
+}
+
+// Ready reports whether every connection is up and can receive events.
+func (h *Health) Ready() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, c := range h.connections {
+		if !c.connected {
+			return false
+		}
+	}
+	return len(h.connections) > 0
+}
+
+// Healthy reports whether every connection is up or has not been down for long.
+// It returns the reason when it is not healthy.
+func (h *Health) Healthy() (bool, string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var names []string
+	for name := range h.connections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := h.connections[name]
+		if c.connected {
+			continue
+		}
+		if down := time.Since(c.changedAt); down > h.gracePeriod {
+			return false, fmt.Sprintf("%s disconnected from Slack for %s", name, down.Round(time.Second))
+		}
+	}
+	return true, ""
+}
+
+// Server serves /metrics, /healthz and /readyz.
+type Server struct {
+	server *http.Server
+}
+
+// NewServer creates a server listening on addr, e.g. ":9090".
+func NewServer(addr string, health *Health) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry}))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if healthy, reason := health.Healthy(); !healthy {
+			http.Error(w, reason, http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !health.Ready() {
+			http.Error(w, "not connected to Slack", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+
+	return &Server{server: &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}}
+}
+
+// Start serves requests in the background until Shutdown is called.
+func (s *Server) Start() {
+	go func() {
+		slog.Info("Serving metrics and health checks", "addr", s.server.Addr)
+		err := s.server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Metrics server stopped", "err", err)
+		}
+	}()
+}
+
+// Shutdown stops the server, waiting for open requests until ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
@@ -3,18 +3,12 @@ package model
 import "time"
 
 type ApplicationDetails struct {
-	VersionID  string `json:"versionId"`
-	Domain     string `json:"domain"`
-	FullDomain string `json:"fullDomain"`
-	Properties struct {
-		AnypointPlatformConfigAnalyticsAgentEnabled string `json:"anypoint.platform.config.analytics.agent.enabled"`
-		MuleEnv                                     string `json:"mule.env"`
-		SecureKey                                   string `json:"secureKey"`
-	} `json:"properties"`
-	PropertiesOptions struct {
-		SecureKey struct {
-			Secure bool `json:"secure"`
-		} `json:"secureKey"`
+	VersionID         string            `json:"versionId"`
+	Domain            string            `json:"domain"`
+	FullDomain        string            `json:"fullDomain"`
+	Properties        map[string]string `json:"properties"`
+	PropertiesOptions map[string]struct {
+		Secure bool `json:"secure"`
 	} `json:"propertiesOptions"`
 	Status  string `json:"status"`
 	Workers struct {
@@ -65,6 +59,8 @@ type ApplicationDetails struct {
 
 type Authorization struct {
 	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
 }
 
 type AssetInformation struct {
@@ -107,14 +103,6 @@ type AssetDownload struct {
 	} `json:"files"`
 }
 
-var ListofEnvId map[string]string = map[string]string{
-	"SO2C-dev":  "",
-	"SO2C-prod": "",
-	"SO2C-sqa":  "",
-	"SO2C-val":  "",
-	//"Sandbox": "bff51d7c-a7d9-4781-b411-5a6fbaed40e1",
-}
-
 type AnypointPlatform struct {
 	User struct {
 		ContributorOfOrganizations []ChildEnv `json:"contributorOfOrganizations"`
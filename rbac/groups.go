@@ -0,0 +1,46 @@
+package rbac
+
+import (
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// GroupDirectory looks up the Slack user groups of a user.
+// Slack only lists members per group, so all groups are fetched and cached for a while.
+type GroupDirectory struct {
+	slackClient *slack.Client
+	ttl         time.Duration
+
+	mu        sync.Mutex
+	fetchedAt time.Time
+	byUser    map[string][]string
+}
+
+func NewGroupDirectory(slackClient *slack.Client, ttl time.Duration) *GroupDirectory {
+	return &GroupDirectory{slackClient: slackClient, ttl: ttl}
+}
+
+// Groups returns the ids and handles of the user groups the user belongs to.
+func (d *GroupDirectory) Groups(userID string) ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.byUser == nil || time.Since(d.fetchedAt) > d.ttl {
+		groups, err := d.slackClient.GetUserGroups(slack.GetUserGroupsOptionIncludeUsers(true))
+		if err != nil {
+			return nil, err
+		}
+
+		byUser := map[string][]string{}
+		for _, group := range groups {
+			for _, member := range group.Users {
+				byUser[member] = append(byUser[member], group.ID, group.Handle)
+			}
+		}
+		d.byUser = byUser
+		d.fetchedAt = time.Now()
+	}
+	return d.byUser[userID], nil
+}
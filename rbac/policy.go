@@ -0,0 +1,240 @@
+package rbac
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Special environment names a rule can use instead of listing environments.
+const (
+	AnyEnvironment        = "*"
+	ProductionEnvironment = "production"
+	NonProductionEnv      = "non-production"
+)
+
+// Policy maps Slack users and user groups to the commands, environments and
+// business groups they may use. Anything no rule allows is denied. For example:
+//
+//	rules:
+//	  - name: everyone-reads
+//	    commands: ["/get-status", "/list-environments", "/get-asset-info"]
+//	  - name: operators
+//	    groups: ["mule-ops"]
+//	    commands: ["/change-status", "/scale-app"]
+//	    environments: ["non-production"]
+type Policy struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule allows its users and groups to run its commands. Empty lists match everything.
+type Rule struct {
+	Name           string   `yaml:"name"`
+	Users          []string `yaml:"users"`
+	Groups         []string `yaml:"groups"`
+	Commands       []string `yaml:"commands"`
+	Environments   []string `yaml:"environments"`
+	BusinessGroups []string `yaml:"businessGroups"`
+}
+
+// Subject is the Slack user asking to do something, with the ids and handles of their user groups.
+type Subject struct {
+	UserID string
+	Groups []string
+}
+
+// Resource is what the subject asks to do. Environment and business group are empty
+// for commands that do not act on one.
+type Resource struct {
+	Command           string
+	Environment       string
+	Production        bool
+	BusinessGroupID   string
+	BusinessGroupName string
+}
+
+// Load reads a policy from a YAML or JSON file.
+// It returns the policy and an error if the file cannot be read or is invalid.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &Policy{}
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	err = decoder.Decode(policy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", path, err)
+	}
+	return policy, policy.Validate()
+}
+
+// Validate checks that every rule can match something.
+func (p *Policy) Validate() error {
+	for i, rule := range p.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		for _, command := range rule.Commands {
+			if command != "*" && !strings.HasPrefix(command, "/") {
+				return fmt.Errorf("rule %s: command %q must start with /", name, command)
+			}
+		}
+	}
+	return nil
+}
+
+// RuleChange is a rule that was added, removed or changed between two policies.
+// Old is empty for added rules and New for removed ones.
+type RuleChange struct {
+	Name string
+	Old  string
+	New  string
+}
+
+// Diff lists the rules that differ between old and next, matched by name. Either policy may be nil.
+func Diff(old, next *Policy) []RuleChange {
+	before, after := old.byName(), next.byName()
+	var changes []RuleChange
+	for _, name := range next.names() {
+		rule := after[name]
+		previous, found := before[name]
+		delete(before, name)
+		switch {
+		case !found:
+			changes = append(changes, RuleChange{Name: name, New: rule.String()})
+		case previous.String() != rule.String():
+			changes = append(changes, RuleChange{Name: name, Old: previous.String(), New: rule.String()})
+		}
+	}
+	for _, name := range old.names() {
+		if rule, found := before[name]; found {
+			changes = append(changes, RuleChange{Name: name, Old: rule.String()})
+		}
+	}
+	return changes
+}
+
+// names returns the names of the rules in order, numbering unnamed rules like Allowed does.
+func (p *Policy) names() []string {
+	if p == nil {
+		return nil
+	}
+	names := make([]string, len(p.Rules))
+	for i, rule := range p.Rules {
+		names[i] = rule.Name
+		if names[i] == "" {
+			names[i] = fmt.Sprintf("#%d", i+1)
+		}
+	}
+	return names
+}
+
+func (p *Policy) byName() map[string]Rule {
+	rules := map[string]Rule{}
+	for i, name := range p.names() {
+		rules[name] = p.Rules[i]
+	}
+	return rules
+}
+
+// String describes what the rule matches, leaving out empty lists.
+func (r Rule) String() string {
+	var parts []string
+	for _, list := range []struct {
+		name   string
+		values []string
+	}{
+		{"users", r.Users},
+		{"groups", r.Groups},
+		{"commands", r.Commands},
+		{"environments", r.Environments},
+		{"businessGroups", r.BusinessGroups},
+	} {
+		if len(list.values) > 0 {
+			parts = append(parts, list.name+"="+strings.Join(list.values, ","))
+		}
+	}
+	if len(parts) == 0 {
+		return "everything"
+	}
+	return strings.Join(parts, " ")
+}
+
+// Allowed reports whether any rule allows the subject to act on the resource.
+// It returns the name of the matching rule.
+func (p *Policy) Allowed(subject Subject, resource Resource) (string, bool) {
+	for i, rule := range p.Rules {
+		if rule.matches(subject, resource) {
+			if rule.Name != "" {
+				return rule.Name, true
+			}
+			return fmt.Sprintf("#%d", i+1), true
+		}
+	}
+	return "", false
+}
+
+func (r Rule) matches(subject Subject, resource Resource) bool {
+	if len(r.Users) > 0 || len(r.Groups) > 0 {
+		if !contains(r.Users, subject.UserID) && !containsAny(r.Groups, subject.Groups) {
+			return false
+		}
+	}
+	if len(r.Commands) > 0 && !contains(r.Commands, resource.Command) && !contains(r.Commands, "*") {
+		return false
+	}
+	if resource.Environment != "" && len(r.Environments) > 0 && !r.matchesEnvironment(resource) {
+		return false
+	}
+	if resource.BusinessGroupID != "" && len(r.BusinessGroups) > 0 &&
+		!contains(r.BusinessGroups, resource.BusinessGroupID) && !contains(r.BusinessGroups, resource.BusinessGroupName) {
+		return false
+	}
+	return true
+}
+
+func (r Rule) matchesEnvironment(resource Resource) bool {
+	for _, env := range r.Environments {
+		switch strings.ToLower(env) {
+		case AnyEnvironment:
+			return true
+		case ProductionEnvironment:
+			if resource.Production {
+				return true
+			}
+		case NonProductionEnv:
+			if !resource.Production {
+				return true
+			}
+		default:
+			if strings.EqualFold(env, resource.Environment) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(list []string, values []string) bool {
+	for _, value := range values {
+		if contains(list, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,449 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jchawla2804/golang-slack-event-listener/anypoint"
+	"github.com/jchawla2804/golang-slack-event-listener/database"
+	"github.com/jchawla2804/golang-slack-event-listener/model"
+)
+
+var ErrNotLoggedIn = errors.New("not logged in to Anypoint Platform")
+
+// ErrNoOrganizations is returned when a login has access to no organization at all.
+var ErrNoOrganizations = errors.New("the login has no access to any organization")
+
+const (
+	// DefaultTokenLifetime is used when the token response does not say when the token expires.
+	DefaultTokenLifetime = 3600 * time.Second
+	// RefreshMargin is how long before expiry a connected app token is re-minted.
+	RefreshMargin = 5 * time.Minute
+)
+
+// Session holds an Anypoint Platform login of a Slack user to one root organization.
+// A user can be logged in to several organizations at once; one of them is active.
+type Session struct {
+	TeamID       string                `json:"teamId"`
+	UserID       string                `json:"userId"`
+	OrgID        string                `json:"orgId"`
+	OrgName      string                `json:"orgName"`
+	ControlPlane anypoint.ControlPlane `json:"controlPlane"`
+	// BusinessGroups are the business groups the login may act on.
+	BusinessGroups    []BusinessGroup   `json:"businessGroups,omitempty"`
+	AuthType          string            `json:"authType"`
+	ClientID          string            `json:"clientId,omitempty"`
+	ClientSecret      string            `json:"clientSecret,omitempty"`
+	AccessToken       string            `json:"accessToken"`
+	ExpiresAt         time.Time         `json:"expiresAt"`
+	RefreshUntil      time.Time         `json:"refreshUntil,omitempty"`
+	BusinessGroupID   string            `json:"businessGroupId"`
+	BusinessGroupName string            `json:"businessGroupName"`
+	Environments      map[string]string `json:"environments"`
+	// ProductionEnvironments marks the environment names Anypoint flags as production.
+	ProductionEnvironments map[string]bool `json:"productionEnvironments,omitempty"`
+
+	// override is set on copies made by WithBusinessGroup, whose business group is never saved.
+	override bool
+}
+
+// BusinessGroup is an Anypoint business group, or the root organization itself.
+type BusinessGroup struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// OrgError is returned when a name matches no business group of the user's logins, or more than one.
+type OrgError struct {
+	Org   string
+	Known []string
+}
+
+func (e *OrgError) Error() string {
+	if len(e.Known) == 0 {
+		return fmt.Sprintf("You are not logged in to an organization or business group called %q.", e.Org)
+	}
+	return fmt.Sprintf("%q matches more than one business group: %s. Use its id instead.", e.Org, strings.Join(e.Known, ", "))
+}
+
+// New creates the session of a Slack user who just logged in.
+// For connected app logins the client credentials are kept so the token can be re-minted.
+func New(teamID, userID string, plane anypoint.ControlPlane, typeOfAuth, username, password string, token model.Authorization) *Session {
+	sess := &Session{
+		TeamID:       teamID,
+		UserID:       userID,
+		ControlPlane: plane,
+		AuthType:     typeOfAuth,
+		Environments: map[string]string{},
+	}
+	sess.setToken(token)
+
+	if typeOfAuth == "oauth" {
+		sess.ClientID = username
+		sess.ClientSecret = password
+	}
+	return sess
+}
+
+// CanRefresh reports whether the access token can be re-minted without the user logging in again.
+// Connected app tokens are re-minted until RefreshUntil, or for as long as Anypoint accepts
+// the client credentials if it is not set.
+func (s *Session) CanRefresh() bool {
+	return s.AuthType == "oauth" && s.ClientID != "" && (s.RefreshUntil.IsZero() || time.Now().Before(s.RefreshUntil))
+}
+
+// IsProduction reports whether the named environment is a production environment.
+func (s *Session) IsProduction(envName string) bool {
+	return s.ProductionEnvironments[envName]
+}
+
+// SetEnvironments replaces the cached environments of the business group with list.
+func (s *Session) SetEnvironments(list model.ListOfEnv) {
+	s.Environments = map[string]string{}
+	s.ProductionEnvironments = map[string]bool{}
+	for _, env := range list.Data {
+		s.Environments[env.Name] = env.ID
+		if env.IsProduction {
+			s.ProductionEnvironments[env.Name] = true
+		}
+	}
+}
+
+// EnvironmentNames returns the names of the cached environments.
+func (s *Session) EnvironmentNames() []string {
+	names := make([]string, 0, len(s.Environments))
+	for name := range s.Environments {
+		names = append(names, name)
+	}
+	return names
+}
+
+// SetOrganizations records the root organization and the business groups of the login.
+func (s *Session) SetOrganizations(platform model.AnypointPlatform) error {
+	orgs := platform.User.ContributorOfOrganizations
+	if len(orgs) == 0 {
+		return ErrNoOrganizations
+	}
+
+	s.OrgID, s.OrgName = orgs[0].ParentId, orgs[0].ParentName
+	s.BusinessGroups = nil
+	for _, org := range orgs {
+		if org.IsRoot {
+			s.OrgID, s.OrgName = org.Id, org.Name
+		}
+		s.BusinessGroups = append(s.BusinessGroups, BusinessGroup{ID: org.Id, Name: org.Name})
+	}
+	if s.OrgID == "" {
+		s.OrgID, s.OrgName = orgs[0].Id, orgs[0].Name
+	}
+	return nil
+}
+
+// UseBusinessGroup makes the login act on a business group, forgetting the environments of the previous one.
+func (s *Session) UseBusinessGroup(group BusinessGroup) {
+	if s.BusinessGroupID == group.ID {
+		return
+	}
+	s.BusinessGroupID = group.ID
+	s.BusinessGroupName = group.Name
+	s.Environments = map[string]string{}
+	s.ProductionEnvironments = map[string]bool{}
+}
+
+// WithBusinessGroup returns a copy of the session that acts on another business group for a single command.
+// Saving the copy only keeps a re-minted access token; the login stays on its own business group.
+func (s *Session) WithBusinessGroup(group BusinessGroup) *Session {
+	if s.BusinessGroupID == group.ID {
+		return s
+	}
+	copied := *s
+	copied.UseBusinessGroup(group)
+	copied.override = true
+	return &copied
+}
+
+// HasBusinessGroup reports whether the user has picked a business group after logging in.
+func (s *Session) HasBusinessGroup() bool {
+	return s.BusinessGroupID != ""
+}
+
+func (s *Session) setToken(token model.Authorization) {
+	lifetime := DefaultTokenLifetime
+	if token.ExpiresIn > 0 {
+		lifetime = time.Duration(token.ExpiresIn) * time.Second
+	}
+	s.AccessToken = token.AccessToken
+	s.ExpiresAt = time.Now().Add(lifetime)
+}
+
+// Manager keeps the sessions of every Slack team and user.
+type Manager struct {
+	store   database.Store
+	options []anypoint.Option
+	// connectedAppLifetime caps how long connected app credentials are kept; 0 keeps them while they work.
+	connectedAppLifetime atomic.Int64
+}
+
+// NewManager creates a session manager on top of the given store.
+// The options are applied to every Anypoint client the manager creates, after the control plane.
+func NewManager(store database.Store, options ...anypoint.Option) *Manager {
+	return &Manager{store: store, options: options}
+}
+
+// SetConnectedAppLifetime caps how long connected app credentials are kept to re-mint tokens
+// after login. With 0 they are kept for as long as Anypoint accepts them.
+// It may be called again while the bot is running; it applies to logins saved afterwards.
+func (m *Manager) SetConnectedAppLifetime(lifetime time.Duration) {
+	m.connectedAppLifetime.Store(int64(lifetime))
+}
+
+// Client returns an Anypoint client for the control plane of the session
+// that authenticates with the session's access token.
+func (m *Manager) Client(sess *Session, options ...anypoint.Option) *anypoint.Client {
+	options = append([]anypoint.Option{
+		anypoint.OptionControlPlane(sess.ControlPlane),
+		anypoint.OptionAuth(tokenProvider{manager: m, sess: sess}),
+	}, append(slices.Clip(m.options), options...)...)
+	return anypoint.New(options...)
+}
+
+// Key returns the store key of a Slack user's login to an organization.
+func Key(teamID, userID, orgID string) string {
+	return "session:" + teamID + ":" + userID + ":" + orgID
+}
+
+// activeKey is the store key of the organization a Slack user has made active.
+func activeKey(teamID, userID string) string {
+	return "active-org:" + teamID + ":" + userID
+}
+
+// Get returns the session of the organization a Slack user has made active.
+// It returns ErrNotLoggedIn if the user has no session or the session has expired.
+func (m *Manager) Get(teamID, userID string) (*Session, error) {
+	orgID, found, err := m.store.Get(activeKey(teamID, userID))
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrNotLoggedIn
+	}
+	return m.GetOrg(teamID, userID, string(orgID))
+}
+
+// GetOrg returns the session of a Slack user's login to an organization, active or not.
+// It returns ErrNotLoggedIn if the user is not logged in to it or the session has expired.
+func (m *Manager) GetOrg(teamID, userID, orgID string) (*Session, error) {
+	return m.load(Key(teamID, userID, orgID))
+}
+
+// List returns the sessions of every organization a Slack user is logged in to, sorted by organization name.
+func (m *Manager) List(teamID, userID string) ([]*Session, error) {
+	keys, err := m.store.Keys(Key(teamID, userID, ""))
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*Session
+	for _, key := range keys {
+		sess, err := m.load(key)
+		if errors.Is(err, ErrNotLoggedIn) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, sess)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].OrgName < list[j].OrgName })
+	return list, nil
+}
+
+// Find returns the session of a Slack user that can act on org, the id or name of a root
+// organization or business group, along with the matching business group.
+// It returns ErrNotLoggedIn if the user has no session and an *OrgError if org matches none or several.
+func (m *Manager) Find(teamID, userID, org string) (*Session, BusinessGroup, error) {
+	list, err := m.List(teamID, userID)
+	if err != nil {
+		return nil, BusinessGroup{}, err
+	}
+	if len(list) == 0 {
+		return nil, BusinessGroup{}, ErrNotLoggedIn
+	}
+
+	type match struct {
+		sess  *Session
+		group BusinessGroup
+	}
+	var matches []match
+	for _, sess := range list {
+		groups := sess.BusinessGroups
+		if !slices.ContainsFunc(groups, func(group BusinessGroup) bool { return group.ID == sess.OrgID }) {
+			groups = append([]BusinessGroup{{ID: sess.OrgID, Name: sess.OrgName}}, groups...)
+		}
+		for _, group := range groups {
+			if group.ID == org {
+				return sess, group, nil
+			}
+			if strings.EqualFold(group.Name, org) {
+				matches = append(matches, match{sess, group})
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 1:
+		return matches[0].sess, matches[0].group, nil
+	case 0:
+		return nil, BusinessGroup{}, &OrgError{Org: org}
+	}
+	var known []string
+	for _, m := range matches {
+		known = append(known, fmt.Sprintf("%s (%s in %s)", m.group.Name, m.group.ID, m.sess.OrgName))
+	}
+	return nil, BusinessGroup{}, &OrgError{Org: org, Known: known}
+}
+
+// Activate makes the session the one Get returns for its user.
+func (m *Manager) Activate(sess *Session) error {
+	return m.store.Set(activeKey(sess.TeamID, sess.UserID), []byte(sess.OrgID), 0)
+}
+
+func (m *Manager) load(key string) (*Session, error) {
+	value, found, err := m.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrNotLoggedIn
+	}
+
+	sess := &Session{}
+	err = json.Unmarshal(value, sess)
+	if err != nil {
+		return nil, err
+	}
+	if sess.Environments == nil {
+		sess.Environments = map[string]string{}
+	}
+	return sess, nil
+}
+
+// AccessToken returns a usable access token for the session.
+// Connected app tokens that are about to expire are re-minted and the session is saved again.
+// It returns ErrNotLoggedIn if the token has expired and cannot be re-minted.
+func (m *Manager) AccessToken(ctx context.Context, sess *Session) (string, error) {
+	if time.Until(sess.ExpiresAt) > RefreshMargin {
+		return sess.AccessToken, nil
+	}
+	if !sess.CanRefresh() {
+		if time.Now().Before(sess.ExpiresAt) {
+			return sess.AccessToken, nil
+		}
+		return "", ErrNotLoggedIn
+	}
+
+	slog.InfoContext(ctx, "Re-minting connected app token", "user", sess.UserID)
+	client := anypoint.New(append([]anypoint.Option{anypoint.OptionControlPlane(sess.ControlPlane)}, m.options...)...)
+	token, err := client.GetToken(ctx, sess.ClientID, sess.ClientSecret, sess.AuthType)
+	if errors.Is(err, anypoint.ErrUnauthorized) {
+		if err := m.store.Delete(Key(sess.TeamID, sess.UserID, sess.OrgID)); err != nil {
+			slog.ErrorContext(ctx, "Could not delete session with rejected credentials", "user", sess.UserID, "err", err)
+		}
+		return "", fmt.Errorf("%w: the connected app credentials were rejected", ErrNotLoggedIn)
+	}
+	if err != nil {
+		return "", fmt.Errorf("re-minting connected app token: %w", err)
+	}
+	sess.setToken(token)
+
+	err = m.Save(sess)
+	if err != nil {
+		return "", err
+	}
+	return sess.AccessToken, nil
+}
+
+// Save stores the session until its access token expires, or for connected app
+// logins until the credentials are no longer used to re-mint tokens.
+// Connected app logins saved for the first time get the configured lifetime, if any.
+// For a copy made by WithBusinessGroup only the access token is saved.
+func (m *Manager) Save(sess *Session) error {
+	if sess.override {
+		stored, err := m.load(Key(sess.TeamID, sess.UserID, sess.OrgID))
+		if err != nil {
+			return err
+		}
+		stored.AccessToken = sess.AccessToken
+		stored.ExpiresAt = sess.ExpiresAt
+		sess = stored
+	}
+
+	lifetime := time.Duration(m.connectedAppLifetime.Load())
+	if sess.CanRefresh() && sess.RefreshUntil.IsZero() && lifetime > 0 {
+		sess.RefreshUntil = time.Now().Add(lifetime)
+	}
+
+	expiresAt := sess.ExpiresAt
+	if sess.RefreshUntil.After(expiresAt) {
+		expiresAt = sess.RefreshUntil
+	}
+	ttl := time.Until(expiresAt)
+	switch {
+	case sess.CanRefresh() && sess.RefreshUntil.IsZero():
+		// Kept until the user logs out or the credentials are rejected.
+		ttl = 0
+	case ttl <= 0:
+		return ErrNotLoggedIn
+	}
+
+	value, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	return m.store.Set(Key(sess.TeamID, sess.UserID, sess.OrgID), value, ttl)
+}
+
+// Count returns the number of Slack users with at least one stored session.
+func (m *Manager) Count() (int, error) {
+	keys, err := m.store.Keys("session:")
+	if err != nil {
+		return 0, err
+	}
+	users := map[string]bool{}
+	for _, key := range keys {
+		users[key[:strings.LastIndex(key, ":")]] = true
+	}
+	return len(users), nil
+}
+
+// Delete removes every session of a Slack user.
+func (m *Manager) Delete(teamID, userID string) error {
+	keys, err := m.store.Keys(Key(teamID, userID, ""))
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := m.store.Delete(key); err != nil {
+			return err
+		}
+	}
+	return m.store.Delete(activeKey(teamID, userID))
+}
+
+// tokenProvider hands the session's access token to the Anypoint client.
+type tokenProvider struct {
+	manager *Manager
+	sess    *Session
+}
+
+func (t tokenProvider) Token(ctx context.Context) (string, error) {
+	return t.manager.AccessToken(ctx, t.sess)
+}
@@ -0,0 +1,297 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jchawla2804/golang-slack-event-listener/anypoint"
+	"github.com/jchawla2804/golang-slack-event-listener/database"
+	"github.com/jchawla2804/golang-slack-event-listener/model"
+)
+
+// tokenServer answers connected app token requests with new-token, or 401 if reject is set.
+// It returns the manager to use it with and the number of token requests it received.
+func tokenServer(t *testing.T, reject bool) (*Manager, *atomic.Int32) {
+	t.Helper()
+	calls := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/accounts/api/v2/oauth2/token" {
+			http.NotFound(w, r)
+			return
+		}
+		calls.Add(1)
+		if reject {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"new-token","expires_in":3600}`))
+	}))
+	t.Cleanup(server.Close)
+	return NewManager(database.NewMemoryStore(), anypoint.OptionBaseURL(server.URL)), calls
+}
+
+func connectedApp(expiresIn time.Duration) *Session {
+	sess := New("T1", "U1", anypoint.US, "oauth", "client-id", "client-secret", model.Authorization{AccessToken: "old-token", ExpiresIn: int(expiresIn.Seconds())})
+	sess.OrgID = "org-1"
+	return sess
+}
+
+func TestAccessTokenWithinRefreshMargin(t *testing.T) {
+	tests := []struct {
+		name      string
+		expiresIn time.Duration
+		want      string
+		calls     int32
+	}{
+		{name: "far from expiry", expiresIn: time.Hour, want: "old-token", calls: 0},
+		{name: "within refresh margin", expiresIn: RefreshMargin - time.Minute, want: "new-token", calls: 1},
+		{name: "expired", expiresIn: -time.Minute, want: "new-token", calls: 1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m, calls := tokenServer(t, false)
+			sess := connectedApp(time.Hour)
+			sess.ExpiresAt = time.Now().Add(tc.expiresIn)
+
+			token, err := m.AccessToken(context.Background(), sess)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token != tc.want {
+				t.Errorf("AccessToken = %q, want %q", token, tc.want)
+			}
+			if got := calls.Load(); got != tc.calls {
+				t.Errorf("token requests = %d, want %d", got, tc.calls)
+			}
+		})
+	}
+}
+
+func TestAccessTokenReMintSavesSession(t *testing.T) {
+	m, _ := tokenServer(t, false)
+	sess := connectedApp(time.Minute)
+	if err := m.Save(sess); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.AccessToken(context.Background(), sess); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := m.GetOrg("T1", "U1", "org-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.AccessToken != "new-token" || time.Until(stored.ExpiresAt) < 59*time.Minute {
+		t.Errorf("stored token = %q expiring in %v, want new-token for an hour", stored.AccessToken, time.Until(stored.ExpiresAt))
+	}
+}
+
+func TestAccessTokenRejectedCredentials(t *testing.T) {
+	m, _ := tokenServer(t, true)
+	sess := connectedApp(time.Minute)
+	if err := m.Save(sess); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := m.AccessToken(context.Background(), sess)
+	if !errors.Is(err, ErrNotLoggedIn) {
+		t.Fatalf("AccessToken = %v, want %v", err, ErrNotLoggedIn)
+	}
+	if _, err := m.GetOrg("T1", "U1", "org-1"); !errors.Is(err, ErrNotLoggedIn) {
+		t.Errorf("session with rejected credentials is still stored: %v", err)
+	}
+}
+
+func TestAccessTokenPasswordLogin(t *testing.T) {
+	m, calls := tokenServer(t, false)
+	sess := New("T1", "U1", anypoint.US, "basic-auth", "user", "password", model.Authorization{AccessToken: "token", ExpiresIn: 60})
+
+	token, err := m.AccessToken(context.Background(), sess)
+	if err != nil || token != "token" {
+		t.Errorf("AccessToken before expiry = %q, %v; want the token", token, err)
+	}
+	sess.ExpiresAt = time.Now().Add(-time.Second)
+	if _, err := m.AccessToken(context.Background(), sess); !errors.Is(err, ErrNotLoggedIn) {
+		t.Errorf("AccessToken after expiry = %v, want %v", err, ErrNotLoggedIn)
+	}
+	if calls.Load() != 0 {
+		t.Error("a password login was re-minted")
+	}
+}
+
+func TestConnectedAppLifetime(t *testing.T) {
+	m, _ := tokenServer(t, false)
+
+	sess := connectedApp(time.Minute)
+	if err := m.Save(sess); err != nil {
+		t.Fatal(err)
+	}
+	if !sess.RefreshUntil.IsZero() {
+		t.Errorf("RefreshUntil = %v without a lifetime, want none", sess.RefreshUntil)
+	}
+	sess.ExpiresAt = time.Now().Add(-time.Hour)
+	if !sess.CanRefresh() {
+		t.Error("a connected app login without a lifetime cannot be refreshed")
+	}
+
+	m.SetConnectedAppLifetime(24 * time.Hour)
+	capped := connectedApp(time.Minute)
+	capped.UserID = "U2"
+	if err := m.Save(capped); err != nil {
+		t.Fatal(err)
+	}
+	if until := time.Until(capped.RefreshUntil); until < 23*time.Hour || until > 24*time.Hour {
+		t.Errorf("RefreshUntil in %v, want in 24h", until)
+	}
+	capped.RefreshUntil = time.Now().Add(-time.Second)
+	capped.ExpiresAt = time.Now().Add(-time.Second)
+	if _, err := m.AccessToken(context.Background(), capped); !errors.Is(err, ErrNotLoggedIn) {
+		t.Errorf("AccessToken past the lifetime = %v, want %v", err, ErrNotLoggedIn)
+	}
+}
+
+// login saves a password login of a user to an organization with its business groups.
+func login(t *testing.T, m *Manager, userID, orgID, orgName string, groups ...BusinessGroup) *Session {
+	t.Helper()
+	sess := New("T1", userID, anypoint.US, "basic-auth", "user", "password", model.Authorization{AccessToken: "token-" + orgID, ExpiresIn: 3600})
+	sess.OrgID, sess.OrgName = orgID, orgName
+	sess.BusinessGroups = groups
+	if err := m.Save(sess); err != nil {
+		t.Fatal(err)
+	}
+	return sess
+}
+
+func TestFind(t *testing.T) {
+	m := NewManager(database.NewMemoryStore())
+	login(t, m, "U1", "org-1", "Acme", BusinessGroup{ID: "bg-1", Name: "Payments"}, BusinessGroup{ID: "bg-2", Name: "Shared"})
+	login(t, m, "U1", "org-2", "Globex", BusinessGroup{ID: "org-2", Name: "Globex"}, BusinessGroup{ID: "bg-3", Name: "shared"})
+
+	tests := []struct {
+		name     string
+		org      string
+		wantOrg  string
+		want     BusinessGroup
+		orgError bool
+	}{
+		{name: "root organization by name", org: "acme", wantOrg: "org-1", want: BusinessGroup{ID: "org-1", Name: "Acme"}},
+		{name: "business group by name", org: "PAYMENTS", wantOrg: "org-1", want: BusinessGroup{ID: "bg-1", Name: "Payments"}},
+		{name: "business group by id", org: "bg-3", wantOrg: "org-2", want: BusinessGroup{ID: "bg-3", Name: "shared"}},
+		{name: "root organization by id", org: "org-2", wantOrg: "org-2", want: BusinessGroup{ID: "org-2", Name: "Globex"}},
+		{name: "ambiguous name", org: "Shared", orgError: true},
+		{name: "unknown", org: "Initech", orgError: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sess, group, err := m.Find("T1", "U1", tc.org)
+			if tc.orgError {
+				var orgErr *OrgError
+				if !errors.As(err, &orgErr) {
+					t.Errorf("Find(%q) = %v, want an OrgError", tc.org, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sess.OrgID != tc.wantOrg || group != tc.want {
+				t.Errorf("Find(%q) = %s, %+v; want %s, %+v", tc.org, sess.OrgID, group, tc.wantOrg, tc.want)
+			}
+		})
+	}
+
+	_, _, err := m.Find("T1", "U1", "Shared")
+	var orgErr *OrgError
+	if !errors.As(err, &orgErr) || len(orgErr.Known) != 2 {
+		t.Errorf("ambiguous Find = %v, want an OrgError listing both business groups", err)
+	}
+	if _, _, err := m.Find("T1", "U2", "Acme"); !errors.Is(err, ErrNotLoggedIn) {
+		t.Errorf("Find for a user without sessions = %v, want %v", err, ErrNotLoggedIn)
+	}
+}
+
+func TestActivate(t *testing.T) {
+	m := NewManager(database.NewMemoryStore())
+	acme := login(t, m, "U1", "org-1", "Acme")
+	globex := login(t, m, "U1", "org-2", "Globex")
+
+	if _, err := m.Get("T1", "U1"); !errors.Is(err, ErrNotLoggedIn) {
+		t.Errorf("Get before Activate = %v, want %v", err, ErrNotLoggedIn)
+	}
+	for _, sess := range []*Session{acme, globex, acme} {
+		if err := m.Activate(sess); err != nil {
+			t.Fatal(err)
+		}
+		active, err := m.Get("T1", "U1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if active.OrgID != sess.OrgID {
+			t.Errorf("Get after activating %s = %s", sess.OrgID, active.OrgID)
+		}
+	}
+
+	if err := m.Delete("T1", "U1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Get("T1", "U1"); !errors.Is(err, ErrNotLoggedIn) {
+		t.Errorf("Get after Delete = %v, want %v", err, ErrNotLoggedIn)
+	}
+}
+
+func TestCount(t *testing.T) {
+	m := NewManager(database.NewMemoryStore())
+	if count, err := m.Count(); err != nil || count != 0 {
+		t.Errorf("Count without sessions = %d, %v; want 0", count, err)
+	}
+
+	m.Activate(login(t, m, "U1", "org-1", "Acme"))
+	login(t, m, "U1", "org-2", "Globex")
+	login(t, m, "U2", "org-1", "Acme")
+	if count, err := m.Count(); err != nil || count != 2 {
+		t.Errorf("Count = %d, %v; want 2 users", count, err)
+	}
+}
+
+func TestSaveWithBusinessGroupKeepsStoredGroup(t *testing.T) {
+	m := NewManager(database.NewMemoryStore())
+	sess := login(t, m, "U1", "org-1", "Acme", BusinessGroup{ID: "bg-1", Name: "Payments"}, BusinessGroup{ID: "bg-2", Name: "Sales"})
+	sess.UseBusinessGroup(BusinessGroup{ID: "bg-1", Name: "Payments"})
+	sess.Environments = map[string]string{"Sandbox": "env-1"}
+	if err := m.Save(sess); err != nil {
+		t.Fatal(err)
+	}
+
+	copied := sess.WithBusinessGroup(BusinessGroup{ID: "bg-2", Name: "Sales"})
+	if copied.BusinessGroupID != "bg-2" || len(copied.Environments) != 0 {
+		t.Errorf("copy acts on %s with environments %v, want bg-2 with none", copied.BusinessGroupID, copied.Environments)
+	}
+	copied.AccessToken = "re-minted"
+	copied.Environments["Production"] = "env-2"
+	if err := m.Save(copied); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := m.GetOrg("T1", "U1", "org-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.BusinessGroupID != "bg-1" || stored.BusinessGroupName != "Payments" {
+		t.Errorf("stored business group = %s (%s), want bg-1 (Payments)", stored.BusinessGroupID, stored.BusinessGroupName)
+	}
+	if len(stored.Environments) != 1 || stored.Environments["Sandbox"] != "env-1" {
+		t.Errorf("stored environments = %v, want those of Payments", stored.Environments)
+	}
+	if stored.AccessToken != "re-minted" {
+		t.Errorf("stored access token = %q, want the one saved with the copy", stored.AccessToken)
+	}
+	if sess.BusinessGroupID != "bg-1" {
+		t.Errorf("WithBusinessGroup changed the original session to %s", sess.BusinessGroupID)
+	}
+}
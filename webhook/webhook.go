@@ -0,0 +1,264 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/jchawla2804/golang-slack-event-listener/logging"
+	"github.com/jchawla2804/golang-slack-event-listener/metrics"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// maxBodySize limits the size of a request Slack sends.
+const maxBodySize = 1 << 20
+
+// Handler handles the events Slack delivers, the same way for HTTP and Socket Mode.
+type Handler interface {
+	HandleEventsAPI(ctx context.Context, event slackevents.EventsAPIEvent)
+	HandleSlashCommand(ctx context.Context, command slack.SlashCommand)
+	HandleInteraction(ctx context.Context, callback slack.InteractionCallback)
+}
+
+// Server receives Events API callbacks, slash commands and interactivity payloads over HTTP.
+// Every request must be signed with the signing secret of the workspace it comes from.
+// Requests are acknowledged right away and handled afterwards, so Slack never waits for a slow handler.
+type Server struct {
+	// signingSecrets maps the team id of every workspace to the signing secret of its Slack app.
+	signingSecrets map[string]string
+	handler        Handler
+	server         *http.Server
+	// ctx is passed to the handlers; it is cancelled by Serve when the server stops.
+	ctx context.Context
+}
+
+// NewServer creates a server listening on addr for the workspaces in signingSecrets, which maps
+// their team ids to the signing secrets of their Slack apps. Requests of other teams are rejected.
+// The endpoints are /slack/events, /slack/commands and /slack/interactions.
+func NewServer(addr string, signingSecrets map[string]string, handler Handler) *Server {
+	s := &Server{signingSecrets: signingSecrets, handler: handler}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slack/events", s.verified(eventTeam, s.handleEvents))
+	mux.HandleFunc("/slack/commands", s.verified(commandTeam, s.handleCommand))
+	mux.HandleFunc("/slack/interactions", s.verified(interactionTeam, s.handleInteraction))
+
+	s.server = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// Serve handles requests until ctx is done, then stops the server waiting for open requests
+// until the shutdown deadline. It returns an error if the server could not listen.
+func (s *Server) Serve(ctx context.Context, shutdownTimeout time.Duration) error {
+	s.ctx = ctx
+	errs := make(chan error, 1)
+	go func() {
+		slog.Info("Receiving Slack events over HTTP", "addr", s.server.Addr)
+		errs <- s.server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := s.server.Shutdown(shutdownCtx)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// teamFunc reads the team id a request comes from out of its body.
+// Requests that belong to no team, such as URL verification, return anyTeam.
+type teamFunc func(body []byte) (teamId string, anyTeam bool)
+
+// verified only passes on POST requests signed with the signing secret of the team they come from.
+// The body is read up front to check the signature and put back for next to read.
+func (s *Server) verified(teamOf teamFunc, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err != nil {
+			http.Error(w, "could not read request", http.StatusBadRequest)
+			return
+		}
+
+		teamId, anyTeam := teamOf(body)
+		var secrets []string
+		if anyTeam {
+			secrets = slices.Collect(maps.Values(s.signingSecrets))
+		} else if secret, found := s.signingSecrets[teamId]; found {
+			secrets = []string{secret}
+		} else {
+			slog.Warn("Rejected Slack request of unknown workspace", "path", r.URL.Path, "remote", r.RemoteAddr, "team", teamId)
+			http.Error(w, "unknown workspace", http.StatusForbidden)
+			return
+		}
+
+		err = verify(r.Header, body, secrets)
+		if err != nil {
+			slog.Warn("Rejected Slack request with invalid signature", "path", r.URL.Path, "remote", r.RemoteAddr, "team", teamId, "err", err)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next(w, r)
+	}
+}
+
+// verify checks the signature of a request against the given signing secrets.
+func verify(header http.Header, body []byte, secrets []string) error {
+	err := errors.New("no signing secret configured")
+	for _, secret := range secrets {
+		var verifier slack.SecretsVerifier
+		verifier, err = slack.NewSecretsVerifier(header, secret)
+		if err == nil {
+			_, err = verifier.Write(body)
+		}
+		if err == nil {
+			err = verifier.Ensure()
+		}
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// eventTeam reads the team of an Events API request. Only URL verification may be signed by any workspace.
+func eventTeam(body []byte) (string, bool) {
+	event := struct {
+		Type   string `json:"type"`
+		TeamID string `json:"team_id"`
+	}{}
+	if json.Unmarshal(body, &event) != nil {
+		return "", false
+	}
+	return event.TeamID, event.Type == slackevents.URLVerification
+}
+
+// commandTeam reads the team of a slash command.
+func commandTeam(body []byte) (string, bool) {
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return "", false
+	}
+	return form.Get("team_id"), false
+}
+
+// interactionTeam reads the team of an interactivity payload.
+func interactionTeam(body []byte) (string, bool) {
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return "", false
+	}
+	payload := struct {
+		Team struct {
+			ID string `json:"id"`
+		} `json:"team"`
+	}{}
+	if json.Unmarshal([]byte(form.Get("payload")), &payload) != nil {
+		return "", false
+	}
+	return payload.Team.ID, false
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "could not read request", http.StatusBadRequest)
+		return
+	}
+	event, err := slackevents.ParseEvent(json.RawMessage(body), slackevents.OptionNoVerifyToken())
+	if err != nil {
+		slog.Warn("Could not parse Events API request", "err", err)
+		http.Error(w, "invalid event", http.StatusBadRequest)
+		return
+	}
+	metrics.HTTPEvent(event.Type)
+
+	switch event.Type {
+	case slackevents.URLVerification:
+		challenge, ok := event.Data.(*slackevents.EventsAPIURLVerificationEvent)
+		if !ok {
+			http.Error(w, "invalid challenge", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, challenge.Challenge)
+
+	case slackevents.CallbackEvent:
+		ctx := s.ctx
+		if callback, ok := event.Data.(*slackevents.EventsAPICallbackEvent); ok {
+			ctx = logging.With(ctx, "event_id", callback.EventID)
+		}
+		ack(w)
+		s.handler.HandleEventsAPI(ctx, event)
+
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) handleCommand(w http.ResponseWriter, r *http.Request) {
+	command, err := slack.SlashCommandParse(r)
+	if err != nil {
+		slog.Warn("Could not parse slash command", "err", err)
+		http.Error(w, "invalid command", http.StatusBadRequest)
+		return
+	}
+	metrics.HTTPEvent("slash_commands")
+
+	ack(w)
+	s.handler.HandleSlashCommand(logging.With(s.ctx, "event_id", command.TriggerID), command)
+}
+
+func (s *Server) handleInteraction(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	callback := slack.InteractionCallback{}
+	err = json.Unmarshal([]byte(r.PostForm.Get("payload")), &callback)
+	if err != nil {
+		slog.Warn("Could not parse interaction payload", "err", err)
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	metrics.HTTPEvent("interactive")
+
+	ack(w)
+	s.handler.HandleInteraction(logging.With(s.ctx, "event_id", callback.TriggerID), callback)
+}
+
+// ack answers Slack with an empty 200 right away, before the event is handed on.
+func ack(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusOK)
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
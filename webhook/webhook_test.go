@@ -0,0 +1,198 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// recorder is a Handler that remembers the teams of the events it was given.
+type recorder struct {
+	mu    sync.Mutex
+	teams []string
+}
+
+func (r *recorder) HandleEventsAPI(ctx context.Context, event slackevents.EventsAPIEvent) {
+	r.record(event.TeamID)
+}
+
+func (r *recorder) HandleSlashCommand(ctx context.Context, command slack.SlashCommand) {
+	r.record(command.TeamID)
+}
+
+func (r *recorder) HandleInteraction(ctx context.Context, callback slack.InteractionCallback) {
+	r.record(callback.Team.ID)
+}
+
+func (r *recorder) record(team string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.teams = append(r.teams, team)
+}
+
+func (r *recorder) handled() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.teams
+}
+
+var testSecrets = map[string]string{"T1": "secret-one", "T2": "secret-two"}
+
+func testServer() (*Server, *recorder) {
+	handler := &recorder{}
+	s := NewServer(":0", testSecrets, handler)
+	s.ctx = context.Background()
+	return s, handler
+}
+
+// signedRequest builds a request to path signed with secret at the given time.
+func signedRequest(path, contentType, body, secret string, at time.Time) *http.Request {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":" + body))
+
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func commandBody(team string) string {
+	return url.Values{"command": {"/get-status"}, "text": {"dev"}, "team_id": {team}, "user_id": {"U1"}}.Encode()
+}
+
+func TestSlashCommandSignature(t *testing.T) {
+	tests := []struct {
+		name    string
+		team    string
+		secret  string
+		at      time.Time
+		status  int
+		handled bool
+	}{
+		{name: "valid", team: "T1", secret: "secret-one", at: time.Now(), status: http.StatusOK, handled: true},
+		{name: "valid for second workspace", team: "T2", secret: "secret-two", at: time.Now(), status: http.StatusOK, handled: true},
+		{name: "stale timestamp", team: "T1", secret: "secret-one", at: time.Now().Add(-10 * time.Minute), status: http.StatusUnauthorized},
+		{name: "wrong secret", team: "T1", secret: "not-the-secret", at: time.Now(), status: http.StatusUnauthorized},
+		{name: "secret of another workspace", team: "T2", secret: "secret-one", at: time.Now(), status: http.StatusUnauthorized},
+		{name: "unknown workspace", team: "T3", secret: "secret-one", at: time.Now(), status: http.StatusForbidden},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, handler := testServer()
+			w := httptest.NewRecorder()
+			s.server.Handler.ServeHTTP(w, signedRequest("/slack/commands", "application/x-www-form-urlencoded", commandBody(tc.team), tc.secret, tc.at))
+
+			if w.Code != tc.status {
+				t.Errorf("status = %d, want %d", w.Code, tc.status)
+			}
+			if handled := len(handler.handled()) > 0; handled != tc.handled {
+				t.Errorf("handled = %v, want %v", handled, tc.handled)
+			}
+		})
+	}
+}
+
+func TestUnsignedRequestIsRejected(t *testing.T) {
+	s, handler := testServer()
+	req := httptest.NewRequest(http.MethodPost, "/slack/commands", strings.NewReader(commandBody("T1")))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized || len(handler.handled()) != 0 {
+		t.Errorf("status = %d, handled = %v; want 401 and nothing handled", w.Code, handler.handled())
+	}
+}
+
+func TestEventSignedForAnotherTeam(t *testing.T) {
+	body := `{"type":"event_callback","team_id":"T2","event_id":"Ev1","event":{"type":"app_mention","user":"U1","text":"hi","channel":"C1"}}`
+	tests := []struct {
+		secret string
+		status int
+	}{
+		{"secret-two", http.StatusOK},
+		{"secret-one", http.StatusUnauthorized},
+	}
+	for _, tc := range tests {
+		s, handler := testServer()
+		w := httptest.NewRecorder()
+		s.server.Handler.ServeHTTP(w, signedRequest("/slack/events", "application/json", body, tc.secret, time.Now()))
+
+		if w.Code != tc.status {
+			t.Errorf("signed with %s: status = %d, want %d", tc.secret, w.Code, tc.status)
+		}
+		if tc.status == http.StatusOK && len(handler.handled()) != 1 {
+			t.Errorf("signed with %s: handled %v, want the event of T2", tc.secret, handler.handled())
+		}
+		if tc.status != http.StatusOK && len(handler.handled()) != 0 {
+			t.Errorf("signed with %s: handled %v, want nothing", tc.secret, handler.handled())
+		}
+	}
+}
+
+func TestInteractionSignedForAnotherTeam(t *testing.T) {
+	body := url.Values{"payload": {`{"type":"block_actions","team":{"id":"T1"},"user":{"id":"U1"}}`}}.Encode()
+
+	s, handler := testServer()
+	w := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(w, signedRequest("/slack/interactions", "application/x-www-form-urlencoded", body, "secret-two", time.Now()))
+	if w.Code != http.StatusUnauthorized || len(handler.handled()) != 0 {
+		t.Errorf("status = %d, handled = %v; want 401 and nothing handled", w.Code, handler.handled())
+	}
+
+	w = httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(w, signedRequest("/slack/interactions", "application/x-www-form-urlencoded", body, "secret-one", time.Now()))
+	if w.Code != http.StatusOK || len(handler.handled()) != 1 {
+		t.Errorf("status = %d, handled = %v; want 200 and the interaction handled", w.Code, handler.handled())
+	}
+}
+
+func TestURLVerification(t *testing.T) {
+	body := `{"type":"url_verification","token":"unused","challenge":"3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P"}`
+
+	for _, secret := range []string{"secret-one", "secret-two"} {
+		s, handler := testServer()
+		w := httptest.NewRecorder()
+		s.server.Handler.ServeHTTP(w, signedRequest("/slack/events", "application/json", body, secret, time.Now()))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("signed with %s: status = %d, want 200", secret, w.Code)
+		}
+		if got := w.Body.String(); got != "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P" {
+			t.Errorf("signed with %s: body = %q, want the challenge", secret, got)
+		}
+		if len(handler.handled()) != 0 {
+			t.Errorf("signed with %s: URL verification was handed to the handler", secret)
+		}
+	}
+
+	s, _ := testServer()
+	w := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(w, signedRequest("/slack/events", "application/json", body, "not-the-secret", time.Now()))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("signed with an unknown secret: status = %d, want 401", w.Code)
+	}
+}
+
+func TestOnlyPostIsAllowed(t *testing.T) {
+	s, _ := testServer()
+	w := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slack/events", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want 405", w.Code)
+	}
+}
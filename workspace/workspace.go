@@ -0,0 +1,123 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/jchawla2804/golang-slack-event-listener/config"
+	"github.com/jchawla2804/golang-slack-event-listener/rbac"
+	"github.com/slack-go/slack"
+)
+
+// Settings are the settings of a workspace that can change while the bot is running.
+type Settings struct {
+	// DefaultChannelID is where production changes are sent for approval. Approvals are off when it is empty.
+	DefaultChannelID string
+	// AdminChannelID is where configuration reloads are announced.
+	AdminChannelID string
+	// OrgID is the Anypoint organization whose Exchange assets /get-asset-info lists for logins to it.
+	OrgID string
+}
+
+// Workspace is a Slack workspace the bot is connected to, with its own tokens and settings.
+type Workspace struct {
+	Name          string
+	TeamID        string
+	Client        *slack.Client
+	SigningSecret string
+	// Groups looks up the Slack user groups of the workspace's users.
+	Groups *rbac.GroupDirectory
+
+	settings atomic.Pointer[Settings]
+}
+
+// Connect creates the Slack client of a configured workspace. If the team id is not
+// configured it is looked up with the bot token.
+func Connect(ctx context.Context, cfg config.Workspace) (*Workspace, error) {
+	client := slack.New(
+		cfg.BotToken,
+		slack.OptionAppLevelToken(cfg.AppToken),
+	)
+
+	teamId := cfg.TeamID
+	if teamId == "" {
+		auth, err := client.AuthTestContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("workspace %s: could not check bot token: %w", cfg.Name, err)
+		}
+		teamId = auth.TeamID
+	}
+
+	ws := &Workspace{
+		Name:          cfg.Name,
+		TeamID:        teamId,
+		Client:        client,
+		SigningSecret: cfg.SigningSecret,
+		Groups:        rbac.NewGroupDirectory(client, 5*time.Minute),
+	}
+	ws.Configure(cfg)
+	return ws, nil
+}
+
+// Settings returns the current settings of the workspace.
+func (w *Workspace) Settings() Settings {
+	return *w.settings.Load()
+}
+
+// Configure applies the live settings of cfg. It may be called while the bot is running.
+func (w *Workspace) Configure(cfg config.Workspace) {
+	w.settings.Store(&Settings{
+		DefaultChannelID: cfg.DefaultChannelID,
+		AdminChannelID:   cfg.AdminChannelID,
+		OrgID:            cfg.OrgID,
+	})
+}
+
+// Registry holds the workspaces the bot is connected to, by team id.
+type Registry struct {
+	list   []*Workspace
+	byTeam map[string]*Workspace
+}
+
+// NewRegistry creates a registry of workspaces. Two workspaces of the same team are an error.
+func NewRegistry(workspaces ...*Workspace) (*Registry, error) {
+	r := &Registry{byTeam: map[string]*Workspace{}}
+	for _, ws := range workspaces {
+		if other, found := r.byTeam[ws.TeamID]; found {
+			return nil, fmt.Errorf("workspaces %s and %s are both team %s", other.Name, ws.Name, ws.TeamID)
+		}
+		r.byTeam[ws.TeamID] = ws
+		r.list = append(r.list, ws)
+	}
+	return r, nil
+}
+
+// Lookup returns the workspace of a Slack team.
+func (r *Registry) Lookup(teamId string) (*Workspace, bool) {
+	if r == nil {
+		return nil, false
+	}
+	ws, found := r.byTeam[teamId]
+	return ws, found
+}
+
+// List returns the workspaces in the order they were configured.
+func (r *Registry) List() []*Workspace {
+	if r == nil {
+		return nil
+	}
+	return r.list
+}
+
+// Configure applies the live settings of the configured workspaces, matched by name.
+func (r *Registry) Configure(workspaces []config.Workspace) {
+	for _, cfg := range workspaces {
+		for _, ws := range r.List() {
+			if ws.Name == cfg.Name {
+				ws.Configure(cfg)
+			}
+		}
+	}
+}
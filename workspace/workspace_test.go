@@ -0,0 +1,67 @@
+package workspace
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jchawla2804/golang-slack-event-listener/config"
+)
+
+// testWorkspace connects a workspace with a team id, which needs no call to Slack.
+func testWorkspace(t *testing.T, cfg config.Workspace) *Workspace {
+	t.Helper()
+	ws, err := Connect(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ws
+}
+
+func TestNewRegistry(t *testing.T) {
+	one := testWorkspace(t, config.Workspace{Name: "one", TeamID: "T1", BotToken: "xoxb-1"})
+	two := testWorkspace(t, config.Workspace{Name: "two", TeamID: "T2", BotToken: "xoxb-2"})
+
+	r, err := NewRegistry(one, two)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws, found := r.Lookup("T2"); !found || ws != two {
+		t.Errorf("Lookup(T2) = %v, %v; want workspace two", ws, found)
+	}
+	if _, found := r.Lookup("T3"); found {
+		t.Error("Lookup found an unknown team")
+	}
+	if list := r.List(); len(list) != 2 || list[0] != one || list[1] != two {
+		t.Errorf("List = %v, want the workspaces in order", list)
+	}
+
+	again := testWorkspace(t, config.Workspace{Name: "again", TeamID: "T1", BotToken: "xoxb-3"})
+	_, err = NewRegistry(one, two, again)
+	if err == nil || !strings.Contains(err.Error(), "workspaces one and again are both team T1") {
+		t.Errorf("NewRegistry with a duplicate team = %v, want an error naming both workspaces", err)
+	}
+}
+
+func TestRegistryConfigure(t *testing.T) {
+	one := testWorkspace(t, config.Workspace{Name: "one", TeamID: "T1", DefaultChannelID: "C1"})
+	two := testWorkspace(t, config.Workspace{Name: "two", TeamID: "T2", DefaultChannelID: "C2", AdminChannelID: "C-ADMIN"})
+	r, err := NewRegistry(one, two)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Listed in another order, with a workspace the bot is not connected to.
+	r.Configure([]config.Workspace{
+		{Name: "three", TeamID: "T3", DefaultChannelID: "C3"},
+		{Name: "two", TeamID: "T2", DefaultChannelID: "C22", OrgID: "org-2"},
+		{Name: "one", TeamID: "T1", DefaultChannelID: "C11"},
+	})
+
+	if got, want := one.Settings(), (Settings{DefaultChannelID: "C11"}); got != want {
+		t.Errorf("settings of one = %+v, want %+v", got, want)
+	}
+	if got, want := two.Settings(), (Settings{DefaultChannelID: "C22", OrgID: "org-2"}); got != want {
+		t.Errorf("settings of two = %+v, want %+v", got, want)
+	}
+}